@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+)
+
+// accounts maps the fabric identities held by the proxy to their ethereum
+// addresses. The address of an identity is derived from the public key of its
+// certificate the same way the evmscc does, so it never needs to be queried.
+// Keys held by ethereum wallets, which sign their transactions themselves,
+// are mapped to an identity by registering their address.
+type accounts struct {
+	mutex      sync.Mutex
+	backend    FabricBackend
+	users      []string
+	addresses  map[string]types.Address
+	registered map[types.Address]string
+}
+
+func newAccounts(backend FabricBackend, users []string) *accounts {
+	return &accounts{
+		backend:    backend,
+		users:      users,
+		addresses:  make(map[string]types.Address),
+		registered: make(map[types.Address]string),
+	}
+}
+
+// holds returns whether the user is one of the identities of the proxy
+func (a *accounts) holds(user string) bool {
+	for _, u := range a.users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// address returns the ethereum address of the user
+func (a *accounts) address(user string) (types.Address, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if address, ok := a.addresses[user]; ok {
+		return address, nil
+	}
+
+	identity, err := a.backend.Identity(user)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	address, err := identityToAddress(identity)
+	if err != nil {
+		return types.Address{}, err
+	}
+	a.addresses[user] = address
+
+	return address, nil
+}
+
+// list returns the addresses of all the identities, in the order the users
+// were configured
+func (a *accounts) list() ([]types.Address, error) {
+	addresses := make([]types.Address, 0, len(a.users))
+	for _, user := range a.users {
+		address, err := a.address(user)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// register maps the address of an ethereum key to the user
+func (a *accounts) register(address types.Address, user string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.registered[address] = user
+}
+
+// user returns the identity the address belongs to
+func (a *accounts) user(address types.Address) (string, bool, error) {
+	a.mutex.Lock()
+	user, ok := a.registered[address]
+	a.mutex.Unlock()
+	if ok {
+		return user, true, nil
+	}
+
+	for _, user := range a.users {
+		userAddress, err := a.address(user)
+		if err != nil {
+			return "", false, err
+		}
+		if userAddress == address {
+			return user, true, nil
+		}
+	}
+	return "", false, nil
+}
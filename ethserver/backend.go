@@ -0,0 +1,55 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// TxStatus is the outcome of a transaction submitted for ordering. Err is set
+// when the commit of the transaction could not be confirmed.
+type TxStatus struct {
+	ValidationCode peer.TxValidationCode
+	Err            error
+}
+
+// ErrTxNotFound is returned by GetTransactionByID and GetBlockByTxID for a
+// transaction that is not on the ledger
+var ErrTxNotFound = errors.New("transaction not found")
+
+// FabricBackend provides the operations on a fabric network the RPC service
+// is built on. Every operation is performed on the given channel with the
+// identity of the given user.
+type FabricBackend interface {
+	// Query evaluates a chaincode function without submitting a transaction
+	Query(channel, user, chaincodeID, fcn string, args [][]byte) ([]byte, error)
+	// ExecuteTx endorses a chaincode invocation and submits it for ordering
+	// without waiting for it to be committed. It returns the chaincode
+	// response payload, the transaction id and a channel on which the outcome
+	// of the commit is delivered.
+	ExecuteTx(channel, user, chaincodeID, fcn string, args [][]byte) ([]byte, string, <-chan TxStatus, error)
+
+	// The ledger queries are answered by the qscc
+	GetChainInfo(channel, user string) (*common.BlockchainInfo, error)
+	GetBlockByNumber(channel, user string, number uint64) (*common.Block, error)
+	GetBlockByHash(channel, user string, hash []byte) (*common.Block, error)
+	GetBlockByTxID(channel, user, txID string) (*common.Block, error)
+	GetTransactionByID(channel, user, txID string) (*peer.ProcessedTransaction, error)
+
+	// Identity returns the serialized msp identity of the user
+	Identity(user string) ([]byte, error)
+
+	// SubscribeBlocks calls the callback with every block committed on the
+	// channel from now on, until the returned function is called
+	SubscribeBlocks(channel, user string, callback func(*common.Block)) (func(), error)
+
+	// Close releases the connections held to the fabric network
+	Close() error
+}
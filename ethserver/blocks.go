@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+type Block struct {
+	Number     types.Quantity `json:"number"`
+	Hash       types.Hash     `json:"hash"`
+	ParentHash types.Hash     `json:"parentHash"`
+	Timestamp  types.Quantity `json:"timestamp"`
+	// Transactions holds either the hashes of the transactions or the
+	// full Transaction objects
+	Transactions []interface{} `json:"transactions"`
+}
+
+// BlockNumberParams are the positional parameters of eth_getBlockByNumber:
+// the block number or tag, followed by whether to return full transaction
+// objects instead of only their hashes
+type BlockNumberParams struct {
+	Block            types.BlockNumberOrTag
+	FullTransactions bool
+}
+
+func (p *BlockNumberParams) UnmarshalJSON(data []byte) error {
+	return unmarshalBlockParams(data, &p.Block, &p.FullTransactions)
+}
+
+// BlockHashParams are the positional parameters of eth_getBlockByHash: the
+// block hash, followed by whether to return full transaction objects instead
+// of only their hashes
+type BlockHashParams struct {
+	Block            types.Hash
+	FullTransactions bool
+}
+
+func (p *BlockHashParams) UnmarshalJSON(data []byte) error {
+	return unmarshalBlockParams(data, &p.Block, &p.FullTransactions)
+}
+
+func unmarshalBlockParams(data []byte, block interface{}, fullTransactions *bool) error {
+	var params []json.RawMessage
+	err := json.Unmarshal(data, &params)
+	if err != nil {
+		return err
+	}
+
+	if len(params) == 0 {
+		return errors.New("missing block parameter")
+	}
+
+	err = json.Unmarshal(params[0], block)
+	if err != nil {
+		return err
+	}
+
+	if len(params) > 1 {
+		return json.Unmarshal(params[1], fullTransactions)
+	}
+
+	return nil
+}
+
+func (req *EthRPCService) BlockNumber(r *http.Request, _ *DataParam, reply *types.Quantity) error {
+	fmt.Println("Recieved a request for BlockNumber")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	number, err := req.latestBlockNumber(user)
+	if err != nil {
+		return err
+	}
+
+	*reply = types.Quantity(number)
+
+	fmt.Println("Returning from BlockNumber, returning: ", *reply)
+
+	return nil
+}
+
+func (req *EthRPCService) GetBlockByNumber(r *http.Request, params *BlockNumberParams, reply *Block) error {
+	fmt.Println("Recieved a request for GetBlockByNumber")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	number, err := req.parseBlockNumber(user, &params.Block)
+	if err != nil {
+		return err
+	}
+
+	block, err := req.backend.GetBlockByNumber(req.channel, user, number)
+	if err != nil {
+		return err
+	}
+
+	blk, err := newBlock(block, req.chaincode.Name, params.FullTransactions)
+	if err != nil {
+		return err
+	}
+	*reply = blk
+
+	fmt.Println("Returning from GetBlockByNumber, returning block: ", blk.Number)
+
+	return nil
+}
+
+func (req *EthRPCService) GetBlockByHash(r *http.Request, params *BlockHashParams, reply *Block) error {
+	fmt.Println("Recieved a request for GetBlockByHash")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	block, err := req.backend.GetBlockByHash(req.channel, user, params.Block[:])
+	if err != nil {
+		fmt.Printf("Failed to query qscc: %s\n", err)
+		return err
+	}
+
+	blk, err := newBlock(block, req.chaincode.Name, params.FullTransactions)
+	if err != nil {
+		return err
+	}
+	*reply = blk
+
+	fmt.Println("Returning from GetBlockByHash, returning block: ", blk.Number)
+
+	return nil
+}
+
+// latestBlockNumber returns the number of the last block committed on the channel
+func (req *EthRPCService) latestBlockNumber(user string) (uint64, error) {
+	info, err := req.backend.GetChainInfo(req.channel, user)
+	if err != nil {
+		fmt.Printf("Failed to query qscc: %s\n", err)
+		return 0, err
+	}
+
+	if info.GetHeight() == 0 {
+		return 0, errors.New("channel has no blocks")
+	}
+
+	return info.GetHeight() - 1, nil
+}
+
+// parseBlockNumber resolves a block number or tag. Fabric has no pending
+// block, so the pending tag, like a missing block, resolves to the latest
+// block.
+func (req *EthRPCService) parseBlockNumber(user string, block *types.BlockNumberOrTag) (uint64, error) {
+	if block == nil {
+		return req.latestBlockNumber(user)
+	}
+
+	switch block.Tag {
+	case types.LatestBlock, types.PendingBlock:
+		return req.latestBlockNumber(user)
+	case types.EarliestBlock:
+		return 0, nil
+	default:
+		return uint64(block.Number), nil
+	}
+}
+
+// newBlock translates a fabric block into its ethereum representation. Only
+// the transactions of the EVM chaincode are included, either as hashes or as
+// full transaction objects.
+func newBlock(block *common.Block, chaincode string, fullTransactions bool) (Block, error) {
+	blkHeader := block.GetHeader()
+
+	blk := Block{
+		Number:       types.Quantity(blkHeader.GetNumber()),
+		Hash:         types.BytesToHash(blkHeader.Hash()),
+		ParentHash:   types.BytesToHash(blkHeader.GetPreviousHash()),
+		Transactions: []interface{}{},
+	}
+
+	for i, envBytes := range block.GetData().GetData() {
+		env := &common.Envelope{}
+		err := proto.Unmarshal(envBytes, env)
+		if err != nil {
+			return Block{}, err
+		}
+
+		chHeader, err := getChannelHeader(envBytes)
+		if err != nil {
+			return Block{}, err
+		}
+
+		// Fabric does not record a block time, use the time the first
+		// transaction of the block was created instead
+		if i == 0 {
+			blk.Timestamp = types.Quantity(chHeader.GetTimestamp().GetSeconds())
+		}
+
+		if chHeader.GetType() != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+			continue
+		}
+
+		_, invokeSpec, _, err := decodeTransaction(env)
+		if err != nil {
+			return Block{}, err
+		}
+
+		if invokeSpec.GetChaincodeSpec().GetChaincodeId().GetName() != chaincode {
+			continue
+		}
+
+		if !fullTransactions {
+			hash, err := transactionHash(invokeSpec, chHeader.GetTxId())
+			if err != nil {
+				return Block{}, err
+			}
+			blk.Transactions = append(blk.Transactions, hash)
+			continue
+		}
+
+		tx, err := newTransaction(block, i, chHeader.GetTxId(), env)
+		if err != nil {
+			return Block{}, err
+		}
+		blk.Transactions = append(blk.Transactions, tx)
+	}
+
+	return blk, nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// DefaultChaincode is the name of the EVM system chaincode
+const DefaultChaincode = "evmscc"
+
+// Chaincode identifies the chaincode running the EVM on a channel. An empty
+// version accepts whichever version is instantiated.
+type Chaincode struct {
+	Name    string
+	Version string
+}
+
+// ParseChaincode parses a chaincode given as <name>[:<version>]
+func ParseChaincode(s string) Chaincode {
+	parts := strings.SplitN(s, ":", 2)
+
+	cc := Chaincode{Name: parts[0]}
+	if len(parts) > 1 {
+		cc.Version = parts[1]
+	}
+	return cc
+}
+
+func (cc Chaincode) String() string {
+	if cc.Version == "" {
+		return cc.Name
+	}
+	return cc.Name + ":" + cc.Version
+}
+
+// chaincodeData holds the leading fields of the ccprovider.ChaincodeData the
+// lscc returns for an instantiated chaincode, the others are skipped when
+// unmarshalling
+type chaincodeData struct {
+	Name    string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Version string `protobuf:"bytes,2,opt,name=version" json:"version,omitempty"`
+}
+
+func (cd *chaincodeData) Reset()         { *cd = chaincodeData{} }
+func (cd *chaincodeData) String() string { return proto.CompactTextString(cd) }
+func (*chaincodeData) ProtoMessage()     {}
+
+// CheckChaincode verifies with the lscc that the EVM chaincode of the service
+// is instantiated on its channel, at the configured version if there is one.
+// The evmscc is a system chaincode, which the lscc does not know about, so it
+// is not checked.
+func (req *EthRPCService) CheckChaincode() error {
+	if req.chaincode.Name == DefaultChaincode {
+		return nil
+	}
+
+	args := [][]byte{[]byte(req.channel), []byte(req.chaincode.Name)}
+	payload, err := req.backend.Query(req.channel, req.user, "lscc", "getccdata", args)
+	if err != nil {
+		return fmt.Errorf("chaincode %s is not instantiated on channel %s: %s", req.chaincode.Name, req.channel, err)
+	}
+
+	cd := &chaincodeData{}
+	if err := proto.Unmarshal(payload, cd); err != nil {
+		return fmt.Errorf("failed to decode the data of chaincode %s on channel %s: %s", req.chaincode.Name, req.channel, err)
+	}
+
+	if req.chaincode.Version != "" && cd.Version != req.chaincode.Version {
+		return fmt.Errorf("chaincode %s is instantiated on channel %s at version %s instead of %s", req.chaincode.Name, req.channel, cd.Version, req.chaincode.Version)
+	}
+
+	return nil
+}
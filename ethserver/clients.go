@@ -0,0 +1,184 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn"
+	"github.com/hyperledger/fabric-sdk-go/pkg/status"
+	"google.golang.org/grpc/codes"
+)
+
+var errPoolClosed = errors.New("channel client pool is closed")
+
+type clientKey struct {
+	channel string
+	user    string
+}
+
+// channelClientPool shares channel clients between requests. A client is
+// created the first time a user accesses a channel and is reused until a
+// request made with it fails to reach the network, at which point it is
+// closed and replaced by a new one. Clients are created without holding the
+// pool lock, so that connecting a client does not hold up the requests made
+// with the other clients.
+type channelClientPool struct {
+	mutex     sync.Mutex
+	newClient func(channel, user string) (apitxn.ChannelClient, error)
+	clients   map[clientKey]apitxn.ChannelClient
+	closed    bool
+}
+
+func newChannelClientPool(newClient func(channel, user string) (apitxn.ChannelClient, error)) *channelClientPool {
+	return &channelClientPool{
+		newClient: newClient,
+		clients:   make(map[clientKey]apitxn.ChannelClient),
+	}
+}
+
+// do calls fn with the client of the user on the channel. If the client is
+// found to be unhealthy, it is replaced, and fn is retried once with the new
+// client when retryable reports that the failed call can safely be made
+// again.
+func (p *channelClientPool) do(channel, user string, retryable func(error) bool, fn func(apitxn.ChannelClient) error) error {
+	client, err := p.get(channel, user)
+	if err != nil {
+		return err
+	}
+
+	err = fn(client)
+	if !connectionFailed(err) {
+		return err
+	}
+
+	p.discard(channel, user, client)
+	if !retryable(err) {
+		return err
+	}
+
+	client, err = p.get(channel, user)
+	if err != nil {
+		return err
+	}
+
+	return fn(client)
+}
+
+func (p *channelClientPool) get(channel, user string) (apitxn.ChannelClient, error) {
+	key := clientKey{channel: channel, user: user}
+	if client, ok, err := p.lookup(key); ok || err != nil {
+		return client, err
+	}
+
+	client, err := p.newClient(channel, user)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		client.Close()
+		return nil, errPoolClosed
+	}
+
+	// A concurrent request may have added a client in the meantime, in which
+	// case that one is shared
+	if existing, ok := p.clients[key]; ok {
+		client.Close()
+		return existing, nil
+	}
+	p.clients[key] = client
+
+	return client, nil
+}
+
+func (p *channelClientPool) lookup(key clientKey) (apitxn.ChannelClient, bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil, false, errPoolClosed
+	}
+
+	client, ok := p.clients[key]
+	return client, ok, nil
+}
+
+// discard closes the client and removes it from the pool, unless it has
+// already been replaced by a concurrent request
+func (p *channelClientPool) discard(channel, user string, client apitxn.ChannelClient) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := clientKey{channel: channel, user: user}
+	if p.clients[key] != client {
+		return
+	}
+
+	delete(p.clients, key)
+	client.Close()
+}
+
+// close closes every client of the pool. Clients can no longer be obtained
+// from the pool afterwards.
+func (p *channelClientPool) close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+
+	var err error
+	for key, client := range p.clients {
+		if closeErr := client.Close(); err == nil {
+			err = closeErr
+		}
+		delete(p.clients, key)
+	}
+
+	return err
+}
+
+// notEndorsed returns whether the connection failure happened while sending
+// the proposal to the endorsers, before the transaction could be sent to the
+// orderer. Only such failures can be retried for a transaction, which may
+// otherwise already be ordered.
+func notEndorsed(err error) bool {
+	s, ok := status.FromError(err)
+	return ok && s.Group == status.EndorserClientStatus && s.Code == status.ConnectionFailed.ToInt32()
+}
+
+// anyFailure lets any connection failure of a query be retried, as queries
+// have no effect
+func anyFailure(error) bool {
+	return true
+}
+
+// connectionFailed returns whether the error shows the client lost its
+// connection to the network, in which case it needs to be recreated
+func connectionFailed(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	switch s.Group {
+	case status.GRPCTransportStatus:
+		return s.Code == int32(codes.Unavailable)
+	case status.EndorserClientStatus, status.OrdererClientStatus, status.ClientStatus:
+		return s.Code == status.ConnectionFailed.ToInt32()
+	default:
+		return false
+	}
+}
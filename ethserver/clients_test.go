@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn"
+	"github.com/hyperledger/fabric-sdk-go/pkg/status"
+	"google.golang.org/grpc/codes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// stubChannelClient only records whether it was closed, none of the
+// ChannelClient operations are expected to be called on it
+type stubChannelClient struct {
+	apitxn.ChannelClient
+	closed bool
+}
+
+func (c *stubChannelClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+var _ = Describe("channelClientPool", func() {
+	var (
+		pool    *channelClientPool
+		created []*stubChannelClient
+		mutex   sync.Mutex
+	)
+
+	BeforeEach(func() {
+		created = nil
+		pool = newChannelClientPool(func(channel, user string) (apitxn.ChannelClient, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			client := &stubChannelClient{}
+			created = append(created, client)
+			return client, nil
+		})
+	})
+
+	It("reuses the client of a user on a channel", func() {
+		var used []apitxn.ChannelClient
+		for i := 0; i < 3; i++ {
+			err := pool.do("channel1", "User1", anyFailure, func(client apitxn.ChannelClient) error {
+				used = append(used, client)
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		Expect(created).To(HaveLen(1))
+		Expect(used).To(ConsistOf(created[0], created[0], created[0]))
+	})
+
+	It("keeps separate clients per channel and user", func() {
+		noop := func(apitxn.ChannelClient) error { return nil }
+		Expect(pool.do("channel1", "User1", anyFailure, noop)).To(Succeed())
+		Expect(pool.do("channel1", "User2", anyFailure, noop)).To(Succeed())
+		Expect(pool.do("channel2", "User1", anyFailure, noop)).To(Succeed())
+		Expect(pool.do("channel1", "User1", anyFailure, noop)).To(Succeed())
+
+		Expect(created).To(HaveLen(3))
+	})
+
+	It("is safe for concurrent use", func() {
+		var (
+			wg    sync.WaitGroup
+			used  = make([]apitxn.ChannelClient, 20)
+			first apitxn.ChannelClient
+		)
+		for i := range used {
+			wg.Add(1)
+			go func(i int) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				Expect(pool.do("channel1", "User1", anyFailure, func(client apitxn.ChannelClient) error {
+					used[i] = client
+					return nil
+				})).To(Succeed())
+			}(i)
+		}
+		wg.Wait()
+
+		// Concurrent requests may each create a client, only one of them
+		// is kept and shared
+		first = used[0]
+		for _, client := range used {
+			Expect(client).To(BeIdenticalTo(first))
+		}
+		for _, client := range created {
+			Expect(client.closed).To(Equal(client != first))
+		}
+	})
+
+	It("does not hold up other users while creating a client", func() {
+		connecting := make(chan struct{})
+		release := make(chan struct{})
+		pool = newChannelClientPool(func(channel, user string) (apitxn.ChannelClient, error) {
+			if user == "User1" {
+				close(connecting)
+				<-release
+			}
+			return &stubChannelClient{}, nil
+		})
+
+		done := make(chan error, 1)
+		go func() {
+			done <- pool.do("channel1", "User1", anyFailure, func(apitxn.ChannelClient) error { return nil })
+		}()
+		<-connecting
+
+		noop := func(apitxn.ChannelClient) error { return nil }
+		Expect(pool.do("channel1", "User2", anyFailure, noop)).To(Succeed())
+
+		close(release)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("closes a client created after the pool was closed", func() {
+		connecting := make(chan struct{})
+		release := make(chan struct{})
+		client := &stubChannelClient{}
+		pool = newChannelClientPool(func(channel, user string) (apitxn.ChannelClient, error) {
+			close(connecting)
+			<-release
+			return client, nil
+		})
+
+		done := make(chan error, 1)
+		go func() {
+			done <- pool.do("channel1", "User1", anyFailure, func(apitxn.ChannelClient) error { return nil })
+		}()
+		<-connecting
+
+		Expect(pool.close()).To(Succeed())
+		close(release)
+		Eventually(done).Should(Receive(MatchError(errPoolClosed)))
+		Expect(client.closed).To(BeTrue())
+	})
+
+	It("replaces a client that lost its connection and retries", func() {
+		calls := 0
+		err := pool.do("channel1", "User1", anyFailure, func(apitxn.ChannelClient) error {
+			calls++
+			if calls == 1 {
+				return status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil)
+			}
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(calls).To(Equal(2))
+		Expect(created).To(HaveLen(2))
+		Expect(created[0].closed).To(BeTrue())
+		Expect(created[1].closed).To(BeFalse())
+	})
+
+	It("only retries a transaction that could not be endorsed", func() {
+		calls := 0
+		err := pool.do("channel1", "User1", notEndorsed, func(apitxn.ChannelClient) error {
+			calls++
+			if calls == 1 {
+				return status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil)
+			}
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(calls).To(Equal(2))
+
+		for _, failure := range []error{
+			status.New(status.OrdererClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil),
+			status.New(status.GRPCTransportStatus, int32(codes.Unavailable), "unavailable", nil),
+		} {
+			calls = 0
+			err := pool.do("channel1", "User1", notEndorsed, func(apitxn.ChannelClient) error {
+				calls++
+				return failure
+			})
+			Expect(err).To(Equal(failure))
+			Expect(calls).To(Equal(1))
+		}
+
+		// The clients that lost their connection are replaced all the same
+		Expect(created).To(HaveLen(3))
+		Expect(created[1].closed).To(BeTrue())
+		Expect(created[2].closed).To(BeTrue())
+	})
+
+	It("keeps the client when the request itself fails", func() {
+		err := pool.do("channel1", "User1", anyFailure, func(apitxn.ChannelClient) error {
+			return errors.New("boom!")
+		})
+		Expect(err).To(MatchError("boom!"))
+
+		Expect(created).To(HaveLen(1))
+		Expect(created[0].closed).To(BeFalse())
+	})
+
+	It("closes every client when closed", func() {
+		noop := func(apitxn.ChannelClient) error { return nil }
+		Expect(pool.do("channel1", "User1", anyFailure, noop)).To(Succeed())
+		Expect(pool.do("channel1", "User2", anyFailure, noop)).To(Succeed())
+
+		Expect(pool.close()).To(Succeed())
+		Expect(created[0].closed).To(BeTrue())
+		Expect(created[1].closed).To(BeTrue())
+
+		Expect(pool.do("channel1", "User1", anyFailure, noop)).To(MatchError(errPoolClosed))
+	})
+})
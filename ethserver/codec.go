@@ -7,30 +7,51 @@ SPDX-License-Identifier: Apache-2.0
 package ethserver
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/gorilla/rpc/v2"
 	"github.com/gorilla/rpc/v2/json2"
 )
 
+const (
+	// maxBatchConcurrency limits how many calls of a batch request are
+	// dispatched at the same time
+	maxBatchConcurrency = 16
+	// maxBatchSize limits how many calls a batch request may contain
+	maxBatchSize = 100
+	// maxRequestSize limits the size of the body of a request, and of a
+	// websocket message
+	maxRequestSize = 5 * 1024 * 1024
+)
+
 type rpcCodec struct {
-	codec *json2.Codec
+	codec  *json2.Codec
+	server *rpc.Server
 }
 
 type codecRequest struct {
 	rpc.CodecRequest
+	server *rpc.Server
 }
 
-func NewRPCCodec() rpc.Codec {
+// NewRPCCodec returns a codec translating ethereum JSON-RPC method names, such
+// as eth_getCode, into the methods registered on the server, such as
+// eth.GetCode
+func NewRPCCodec(server *rpc.Server) rpc.Codec {
 	codec := json2.NewCodec()
-	return &rpcCodec{codec: codec}
+	return &rpcCodec{codec: codec, server: server}
 }
 
 func (c *rpcCodec) NewRequest(r *http.Request) rpc.CodecRequest {
 	req := c.codec.NewRequest(r)
-	return &codecRequest{req}
+	return &codecRequest{CodecRequest: req, server: c.server}
 }
 
 func (r *codecRequest) Method() (string, error) {
@@ -38,8 +59,157 @@ func (r *codecRequest) Method() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	method := strings.Split(m, "_")
+
+	method := strings.SplitN(m, "_", 2)
+	if len(method) != 2 || method[1] == "" {
+		return "", methodNotFoundError(m)
+	}
+
 	modifiedMethod := fmt.Sprintf("%s.%s", method[0], strings.Title(method[1]))
+	if !r.server.HasMethod(modifiedMethod) {
+		return "", methodNotFoundError(m)
+	}
+
 	fmt.Println("Request came in for this method: ", modifiedMethod)
 	return modifiedMethod, nil
 }
+
+// ReadRequest reports parameters that cannot be decoded into the arguments of
+// the method as invalid params rather than as an invalid request
+func (r *codecRequest) ReadRequest(args interface{}) error {
+	err := r.CodecRequest.ReadRequest(args)
+	if rpcErr, ok := err.(*json2.Error); ok && rpcErr.Code == ErrInvalidRequest {
+		return &json2.Error{
+			Code:    ErrInvalidParams,
+			Message: rpcErr.Message,
+		}
+	}
+	return err
+}
+
+// batchHandler adds support for JSON-RPC batch requests to the handler. The
+// calls of a batch are dispatched individually and concurrently to the
+// handler, and their responses are returned as an array in the order of the
+// calls. Notifications have no response and are left out of the array.
+func batchHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		r.Body.Close()
+		if err != nil {
+			rpc.WriteError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) == 0 || trimmed[0] != '[' {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		var calls []json.RawMessage
+		if err := json.Unmarshal(trimmed, &calls); err != nil {
+			writeBatchError(w, json2.E_PARSE, err.Error())
+			return
+		}
+
+		if len(calls) == 0 {
+			writeBatchError(w, json2.E_INVALID_REQ, "empty batch")
+			return
+		}
+		if len(calls) > maxBatchSize {
+			writeBatchError(w, json2.E_INVALID_REQ, fmt.Sprintf("batch of %d calls exceeds the limit of %d calls", len(calls), maxBatchSize))
+			return
+		}
+
+		responses := make([][]byte, len(calls))
+		semaphore := make(chan struct{}, maxBatchConcurrency)
+		var wg sync.WaitGroup
+		for i, call := range calls {
+			if c := bytes.TrimSpace(call); len(c) == 0 || c[0] != '{' {
+				responses[i] = errorResponseBytes(json2.E_INVALID_REQ, "invalid request")
+				continue
+			}
+
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(i int, call json.RawMessage) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				callReq, err := http.NewRequest("POST", r.URL.String(), bytes.NewReader(call))
+				if err != nil {
+					responses[i] = errorResponseBytes(json2.E_INTERNAL, err.Error())
+					return
+				}
+				callReq.Header = r.Header
+				callReq.RemoteAddr = r.RemoteAddr
+
+				buf := newResponseBuffer()
+				handler.ServeHTTP(buf, callReq)
+				responses[i] = bytes.TrimSpace(buf.body.Bytes())
+			}(i, call)
+		}
+		wg.Wait()
+
+		results := make([][]byte, 0, len(responses))
+		for _, res := range responses {
+			if len(res) > 0 {
+				results = append(results, res)
+			}
+		}
+
+		// A batch made up of notifications only gets no response at all
+		if len(results) == 0 {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte("["))
+		w.Write(bytes.Join(results, []byte(",")))
+		w.Write([]byte("]"))
+	})
+}
+
+func writeBatchError(w http.ResponseWriter, code json2.ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(errorResponseBytes(code, message))
+}
+
+// errorResponseBytes encodes an error response for a request whose id could
+// not be determined
+func errorResponseBytes(code json2.ErrorCode, message string) []byte {
+	res, _ := json.Marshal(errorResponse(nil, code, errors.New(message)))
+	return res
+}
+
+// responseBuffer is an http.ResponseWriter that keeps the response in memory
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{
+		header: make(http.Header),
+		status: http.StatusOK,
+	}
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	b.status = status
+}
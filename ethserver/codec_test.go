@@ -0,0 +1,222 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/gorilla/rpc/v2/json2"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// batchTestService echoes its argument, fails with it as the error message,
+// or blocks until released while counting the calls it is blocked in
+type batchTestService struct {
+	inFlight    int32
+	maxInFlight int32
+	release     chan struct{}
+}
+
+func (s *batchTestService) Echo(r *http.Request, arg *string, reply *string) error {
+	// Delay the first calls so that they complete after the later ones
+	if *arg == "slow" {
+		time.Sleep(20 * time.Millisecond)
+	}
+	*reply = *arg
+	return nil
+}
+
+func (s *batchTestService) Fail(r *http.Request, arg *string, reply *string) error {
+	return errors.New(*arg)
+}
+
+func (s *batchTestService) Wait(r *http.Request, arg *string, reply *string) error {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if n <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, n) {
+			break
+		}
+	}
+
+	<-s.release
+	*reply = *arg
+	return nil
+}
+
+type batchTestResponse struct {
+	ID     *json.RawMessage `json:"id"`
+	Result *string          `json:"result"`
+	Error  *json2.Error     `json:"error"`
+}
+
+var _ = Describe("batchHandler", func() {
+	var (
+		service *batchTestService
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		service = &batchTestService{release: make(chan struct{})}
+
+		server := rpc.NewServer()
+		server.RegisterCodec(NewRPCCodec(server), "application/json")
+		server.RegisterService(service, "test")
+		handler = batchHandler(server)
+	})
+
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	decodeBatch := func(rec *httptest.ResponseRecorder) []batchTestResponse {
+		var responses []batchTestResponse
+		Expect(json.Unmarshal(rec.Body.Bytes(), &responses)).To(Succeed())
+		return responses
+	}
+
+	call := func(method, arg, id string) string {
+		if id == "" {
+			return `{"jsonrpc":"2.0","method":"` + method + `","params":["` + arg + `"]}`
+		}
+		return `{"jsonrpc":"2.0","method":"` + method + `","params":["` + arg + `"],"id":` + id + `}`
+	}
+
+	It("passes single requests through", func() {
+		var res batchTestResponse
+		Expect(json.Unmarshal(serve(call("test_echo", "a", "7")).Body.Bytes(), &res)).To(Succeed())
+		Expect(string(*res.ID)).To(Equal("7"))
+		Expect(*res.Result).To(Equal("a"))
+	})
+
+	It("returns the responses in the order of the calls", func() {
+		rec := serve("[" + strings.Join([]string{
+			call("test_echo", "slow", "1"),
+			call("test_echo", "b", `"two"`),
+			call("test_echo", "c", "3"),
+		}, ",") + "]")
+		Expect(rec.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+
+		responses := decodeBatch(rec)
+		Expect(responses).To(HaveLen(3))
+		for i, expected := range []struct{ id, result string }{{"1", "slow"}, {`"two"`, "b"}, {"3", "c"}} {
+			Expect(string(*responses[i].ID)).To(Equal(expected.id))
+			Expect(*responses[i].Result).To(Equal(expected.result))
+		}
+	})
+
+	It("returns the errors of the calls with their ids", func() {
+		responses := decodeBatch(serve("[" + strings.Join([]string{
+			call("test_fail", "boom", "1"),
+			call("test_unknown", "a", "2"),
+			call("test_echo", "a", "3"),
+		}, ",") + "]"))
+
+		Expect(responses).To(HaveLen(3))
+		Expect(string(*responses[0].ID)).To(Equal("1"))
+		Expect(responses[0].Error.Code).To(Equal(ErrServer))
+		Expect(responses[0].Error.Message).To(Equal("boom"))
+		Expect(string(*responses[1].ID)).To(Equal("2"))
+		Expect(responses[1].Error.Code).To(Equal(ErrMethodNotFound))
+		Expect(string(*responses[2].ID)).To(Equal("3"))
+		Expect(*responses[2].Result).To(Equal("a"))
+	})
+
+	It("rejects the entries that are not request objects", func() {
+		responses := decodeBatch(serve(`[1, "call", ` + call("test_echo", "a", "3") + `]`))
+
+		Expect(responses).To(HaveLen(3))
+		for _, res := range responses[:2] {
+			Expect(res.ID).To(BeNil())
+			Expect(res.Error.Code).To(Equal(ErrInvalidRequest))
+		}
+		Expect(*responses[2].Result).To(Equal("a"))
+	})
+
+	It("leaves the notifications out of the responses", func() {
+		responses := decodeBatch(serve("[" + call("test_echo", "a", "") + "," + call("test_echo", "b", "2") + "]"))
+		Expect(responses).To(HaveLen(1))
+		Expect(*responses[0].Result).To(Equal("b"))
+	})
+
+	It("returns no response body for a batch of notifications", func() {
+		rec := serve("[" + call("test_echo", "a", "") + "," + call("test_fail", "b", "") + "]")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.Len()).To(Equal(0))
+	})
+
+	It("rejects an empty batch as an invalid request", func() {
+		var res batchTestResponse
+		Expect(json.Unmarshal(serve("[]").Body.Bytes(), &res)).To(Succeed())
+		Expect(res.ID).To(BeNil())
+		Expect(res.Error.Code).To(Equal(ErrInvalidRequest))
+		Expect(res.Error.Message).To(Equal("empty batch"))
+	})
+
+	It("rejects a batch of more than maxBatchSize calls as an invalid request", func() {
+		calls := make([]string, maxBatchSize+1)
+		for i := range calls {
+			calls[i] = call("test_echo", "a", "1")
+		}
+
+		var res batchTestResponse
+		Expect(json.Unmarshal(serve("["+strings.Join(calls, ",")+"]").Body.Bytes(), &res)).To(Succeed())
+		Expect(res.Error.Code).To(Equal(ErrInvalidRequest))
+		Expect(res.Error.Message).To(Equal("batch of 101 calls exceeds the limit of 100 calls"))
+
+		responses := decodeBatch(serve("[" + strings.Join(calls[1:], ",") + "]"))
+		Expect(responses).To(HaveLen(maxBatchSize))
+	})
+
+	It("rejects requests larger than maxRequestSize", func() {
+		rec := serve(call("test_echo", strings.Repeat("a", maxRequestSize), "1"))
+		Expect(rec.Code).To(Equal(http.StatusRequestEntityTooLarge))
+	})
+
+	It("rejects a malformed batch as a parse error", func() {
+		var res batchTestResponse
+		Expect(json.Unmarshal(serve("[{").Body.Bytes(), &res)).To(Succeed())
+		Expect(res.Error.Code).To(Equal(ErrParse))
+	})
+
+	It("dispatches at most maxBatchConcurrency calls at a time", func() {
+		calls := make([]string, 3*maxBatchConcurrency)
+		for i := range calls {
+			calls[i] = call("test_wait", "a", "1")
+		}
+
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- serve("[" + strings.Join(calls, ",") + "]")
+		}()
+
+		inFlight := func() int32 { return atomic.LoadInt32(&service.inFlight) }
+		Eventually(inFlight).Should(Equal(int32(maxBatchConcurrency)))
+		Consistently(inFlight, 50*time.Millisecond).Should(Equal(int32(maxBatchConcurrency)))
+
+		close(service.release)
+		var rec *httptest.ResponseRecorder
+		Eventually(done).Should(Receive(&rec))
+		Expect(decodeBatch(rec)).To(HaveLen(len(calls)))
+		Expect(atomic.LoadInt32(&service.maxInFlight)).To(Equal(int32(maxBatchConcurrency)))
+	})
+})
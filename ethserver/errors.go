@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	"github.com/hyperledger/fabric-sdk-go/pkg/status"
+)
+
+// Error codes of the JSON-RPC error responses. Besides the codes defined by
+// the JSON-RPC 2.0 specification, failed endorsements get their own code in
+// the server error range and EVM reverts use the code ethereum clients
+// expect for reverted executions.
+const (
+	ErrParse          = json2.E_PARSE
+	ErrInvalidRequest = json2.E_INVALID_REQ
+	ErrMethodNotFound = json2.E_NO_METHOD
+	ErrInvalidParams  = json2.E_BAD_PARAMS
+	ErrInternal       = json2.E_INTERNAL
+	ErrServer         = json2.E_SERVER
+
+	ErrEndorsementFailed json2.ErrorCode = -32010
+	ErrExecutionReverted json2.ErrorCode = 3
+)
+
+func methodNotFoundError(method string) *json2.Error {
+	return &json2.Error{
+		Code:    ErrMethodNotFound,
+		Message: fmt.Sprintf("the method %s does not exist/is not available", method),
+	}
+}
+
+func invalidParamsError(format string, args ...interface{}) *json2.Error {
+	return &json2.Error{
+		Code:    ErrInvalidParams,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// alreadyKnownError reports a signed transaction that was already submitted
+func alreadyKnownError(hash types.Hash) *json2.Error {
+	return &json2.Error{
+		Code:    ErrServer,
+		Message: fmt.Sprintf("already known: transaction %s was already submitted", hash),
+	}
+}
+
+// nonceTooLowError reports a signed transaction whose nonce was already used
+// by its signer
+func nonceTooLowError(nonce, next uint64) *json2.Error {
+	return &json2.Error{
+		Code:    ErrServer,
+		Message: fmt.Sprintf("nonce too low: nonce %d was already used, next nonce is %d", nonce, next),
+	}
+}
+
+// fabricError translates an error returned by the fabric sdk into a JSON-RPC
+// error. Failed endorsements and EVM reverts get their own codes, any other
+// error is returned unchanged and reported as a server error.
+//
+// The evmscc reports a revert as a chaincode error whose message mentions the
+// revert, with the data returned by the reverted execution as the response
+// payload. That data is passed on hex encoded in the data of the error so
+// clients can decode the revert reason.
+func fabricError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch s.Group {
+	case status.EndorserServerStatus:
+		if strings.Contains(strings.ToLower(s.Message), "revert") {
+			rpcErr := &json2.Error{
+				Code:    ErrExecutionReverted,
+				Message: "execution reverted",
+			}
+
+			// Details of an endorser status are the endorser and the
+			// response payload
+			if len(s.Details) > 1 {
+				if data, ok := s.Details[1].([]byte); ok && len(data) > 0 {
+					rpcErr.Data = "0x" + hex.EncodeToString(data)
+				}
+			}
+			return rpcErr
+		}
+
+		return &json2.Error{
+			Code:    ErrEndorsementFailed,
+			Message: fmt.Sprintf("endorsement failed: %s", s.Message),
+		}
+	case status.EndorserClientStatus:
+		if s.Code == status.ConnectionFailed.ToInt32() {
+			return err
+		}
+
+		return &json2.Error{
+			Code:    ErrEndorsementFailed,
+			Message: fmt.Sprintf("endorsement failed: %s", s.Message),
+		}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabric-client/events"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	sdkcommon "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+// blockEvents fans out the blocks delivered by a single event hub connection
+// to every subscriber. The event hub is connected on the first subscription.
+type blockEvents struct {
+	mutex       sync.Mutex
+	connect     func() (apifabclient.EventHub, error)
+	eventHub    apifabclient.EventHub
+	nextID      int
+	subscribers map[int]func(*common.Block)
+}
+
+// subscribe calls the callback with every block delivered by the event hub
+// from now on, until the returned function is called
+func (e *blockEvents) subscribe(callback func(*common.Block)) (func(), error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.eventHub == nil {
+		eventHub, err := e.connect()
+		if err != nil {
+			return nil, err
+		}
+		eventHub.RegisterBlockEvent(e.publish)
+		e.eventHub = eventHub
+	}
+
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]func(*common.Block))
+	}
+
+	id := e.nextID
+	e.nextID++
+	e.subscribers[id] = callback
+
+	return func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		delete(e.subscribers, id)
+	}, nil
+}
+
+// close drops all subscribers and disconnects the event hub
+func (e *blockEvents) close() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.subscribers = nil
+	if e.eventHub == nil {
+		return nil
+	}
+
+	e.eventHub.UnregisterBlockEvent(e.publish)
+	err := e.eventHub.Disconnect()
+	e.eventHub = nil
+	return err
+}
+
+func (e *blockEvents) publish(sdkBlock *sdkcommon.Block) {
+	// The sdk delivers its own copy of the fabric protos, convert the block
+	// to the protos used throughout the server
+	b, err := proto.Marshal(sdkBlock)
+	if err != nil {
+		fmt.Printf("Failed to marshal block event: %s\n", err)
+		return
+	}
+
+	block := &common.Block{}
+	err = proto.Unmarshal(b, block)
+	if err != nil {
+		fmt.Printf("Failed to unmarshal block event: %s\n", err)
+		return
+	}
+
+	e.mutex.Lock()
+	subscribers := make([]func(*common.Block), 0, len(e.subscribers))
+	for _, s := range e.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	e.mutex.Unlock()
+
+	for _, s := range subscribers {
+		s(block)
+	}
+}
+
+// connectEventHub connects to the event source peer of the channel that
+// belongs to the organization of the user
+func (b *fabricBackend) connectEventHub(channel, user string) (apifabclient.EventHub, error) {
+	session, err := b.sdk.NewClient(fabsdk.WithUser(user)).Session()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := b.sdk.NewSystemClient(session)
+	if err != nil {
+		return nil, err
+	}
+
+	channelPeers, err := client.Config().ChannelPeers(channel)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range channelPeers {
+		if !p.EventSource || p.MspID != session.Identity().MspID() {
+			continue
+		}
+
+		eventHub, err := events.NewEventHubFromConfig(client, &p.PeerConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		err = eventHub.Connect()
+		if err != nil {
+			return nil, err
+		}
+
+		return eventHub, nil
+	}
+
+	return nil, errors.New("unable to find peer event source for channel " + channel)
+}
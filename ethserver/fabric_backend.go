@@ -0,0 +1,213 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hyperledger/fabric-sdk-go/api/apifabclient"
+	"github.com/hyperledger/fabric-sdk-go/api/apitxn"
+	"github.com/hyperledger/fabric-sdk-go/pkg/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// fabricBackend implements the FabricBackend on top of the fabric-sdk-go
+type fabricBackend struct {
+	sdk     *fabsdk.FabricSDK
+	clients *channelClientPool
+
+	mutex       sync.Mutex
+	blockEvents map[string]*blockEvents
+}
+
+// NewFabricBackend creates a backend connected to the fabric network
+// described by the sdk config file
+func NewFabricBackend(configFile string) (FabricBackend, error) {
+	sdk, err := fabsdk.New(config.FromFile(configFile))
+	if err != nil {
+		return nil, fmt.Errorf("error creating sdk: %s", err)
+	}
+
+	return &fabricBackend{
+		sdk: sdk,
+		clients: newChannelClientPool(func(channel, user string) (apitxn.ChannelClient, error) {
+			return sdk.NewChannelClient(channel, user)
+		}),
+		blockEvents: make(map[string]*blockEvents),
+	}, nil
+}
+
+func (b *fabricBackend) Query(channel, user, chaincodeID, fcn string, args [][]byte) ([]byte, error) {
+	var payload []byte
+	err := b.clients.do(channel, user, anyFailure, func(chClient apitxn.ChannelClient) error {
+		var err error
+		payload, err = chClient.Query(apitxn.QueryRequest{
+			ChaincodeID: chaincodeID,
+			Fcn:         fcn,
+			Args:        args,
+		})
+		return err
+	})
+
+	return payload, err
+}
+
+func (b *fabricBackend) ExecuteTx(channel, user, chaincodeID, fcn string, args [][]byte) ([]byte, string, <-chan TxStatus, error) {
+	// The sdk may report more than once for a transaction whose event hub
+	// failed to connect, leave room so it never blocks
+	notifier := make(chan apitxn.ExecuteTxResponse, 2)
+
+	// The transaction is only retried when it could not be endorsed, once
+	// endorsed it may have reached the orderer even if the call failed
+	var payload []byte
+	var txID apitxn.TransactionID
+	err := b.clients.do(channel, user, notEndorsed, func(chClient apitxn.ChannelClient) error {
+		var err error
+		payload, txID, err = chClient.ExecuteTxWithOpts(apitxn.ExecuteTxRequest{
+			ChaincodeID: chaincodeID,
+			Fcn:         fcn,
+			Args:        args,
+		}, apitxn.ExecuteTxOpts{Notifier: notifier})
+		return err
+	})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	statuses := make(chan TxStatus, 1)
+	go func() {
+		resp := <-notifier
+		statuses <- TxStatus{
+			ValidationCode: peer.TxValidationCode(resp.TxValidationCode),
+			Err:            resp.Error,
+		}
+	}()
+
+	return payload, txID.ID, statuses, nil
+}
+
+func (b *fabricBackend) GetChainInfo(channel, user string) (*common.BlockchainInfo, error) {
+	info := &common.BlockchainInfo{}
+	err := b.queryLedger(channel, user, "GetChainInfo", nil, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (b *fabricBackend) GetBlockByNumber(channel, user string, number uint64) (*common.Block, error) {
+	block := &common.Block{}
+	err := b.queryLedger(channel, user, "GetBlockByNumber", []byte(strconv.FormatUint(number, 10)), block)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (b *fabricBackend) GetBlockByHash(channel, user string, hash []byte) (*common.Block, error) {
+	block := &common.Block{}
+	err := b.queryLedger(channel, user, "GetBlockByHash", hash, block)
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (b *fabricBackend) GetBlockByTxID(channel, user, txID string) (*common.Block, error) {
+	block := &common.Block{}
+	err := b.queryLedger(channel, user, "GetBlockByTxID", []byte(txID), block)
+	if err != nil {
+		return nil, txNotFound(err)
+	}
+	return block, nil
+}
+
+func (b *fabricBackend) GetTransactionByID(channel, user, txID string) (*peer.ProcessedTransaction, error) {
+	tx := &peer.ProcessedTransaction{}
+	err := b.queryLedger(channel, user, "GetTransactionByID", []byte(txID), tx)
+	if err != nil {
+		return nil, txNotFound(err)
+	}
+	return tx, nil
+}
+
+func (b *fabricBackend) Identity(user string) ([]byte, error) {
+	session, err := b.sdk.NewClient(fabsdk.WithUser(user)).Session()
+	if err != nil {
+		return nil, err
+	}
+
+	return session.Identity().Identity()
+}
+
+// queryLedger queries the qscc for the channel and unmarshals the response
+// into msg. The qscc takes the channel name as its first argument.
+func (b *fabricBackend) queryLedger(channel, user, fcn string, arg []byte, msg proto.Message) error {
+	args := [][]byte{[]byte(channel)}
+	if arg != nil {
+		args = append(args, arg)
+	}
+
+	payload, err := b.Query(channel, user, "qscc", fcn, args)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(payload, msg)
+}
+
+// txNotFound translates the error the qscc returns for a transaction ID that
+// is not in the index of the ledger into ErrTxNotFound. Depending on the
+// fabric version, the ledger reports it as an entry not found in the index or
+// as no such transaction ID.
+func txNotFound(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "Entry not found in index") || strings.Contains(msg, "no such transaction ID") {
+		return ErrTxNotFound
+	}
+	return err
+}
+
+// SubscribeBlocks shares a single event hub connection per channel between
+// all subscribers. The connection is made with the identity of the first
+// subscriber.
+func (b *fabricBackend) SubscribeBlocks(channel, user string, callback func(*common.Block)) (func(), error) {
+	b.mutex.Lock()
+	e, ok := b.blockEvents[channel]
+	if !ok {
+		e = &blockEvents{
+			connect: func() (apifabclient.EventHub, error) {
+				return b.connectEventHub(channel, user)
+			},
+		}
+		b.blockEvents[channel] = e
+	}
+	b.mutex.Unlock()
+
+	return e.subscribe(callback)
+}
+
+// Close closes the pooled channel clients and disconnects the event hubs
+func (b *fabricBackend) Close() error {
+	err := b.clients.close()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, e := range b.blockEvents {
+		if closeErr := e.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
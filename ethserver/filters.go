@@ -0,0 +1,260 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+)
+
+// DefaultFilterTimeout is how long a filter is kept without being polled
+// before it is uninstalled
+const DefaultFilterTimeout = 5 * time.Minute
+
+type filterType int
+
+const (
+	logFilter filterType = iota
+	blockFilter
+)
+
+// filter tracks the next block to be reported for an installed filter. The
+// mutex is held while the filter is being polled so concurrent polls of the
+// same filter never report a block twice.
+type filter struct {
+	sync.Mutex
+	kind      filterType
+	criteria  FilterCriteria
+	nextBlock uint64
+	lastPoll  time.Time
+}
+
+// filterRegistry holds the filters installed through the RPC service and
+// uninstalls the ones that have not been polled within the timeout. The
+// clock is a field so tests can move time forward without sleeping.
+type filterRegistry struct {
+	mutex   sync.Mutex
+	filters map[string]*filter
+	timeout time.Duration
+	now     func() time.Time
+}
+
+func newFilterRegistry(timeout time.Duration) *filterRegistry {
+	return &filterRegistry{
+		filters: make(map[string]*filter),
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+func (r *filterRegistry) add(f *filter) (string, error) {
+	idBytes := make([]byte, 16)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return "", err
+	}
+	id := "0x" + hex.EncodeToString(idBytes)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.expire()
+
+	f.lastPoll = r.now()
+	r.filters[id] = f
+	return id, nil
+}
+
+// get returns the filter and marks it as polled
+func (r *filterRegistry) get(id string) (*filter, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.expire()
+
+	f, ok := r.filters[id]
+	if ok {
+		f.lastPoll = r.now()
+	}
+	return f, ok
+}
+
+func (r *filterRegistry) remove(id string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.expire()
+
+	_, ok := r.filters[id]
+	delete(r.filters, id)
+	return ok
+}
+
+// expire removes the filters that timed out. It must be called with the
+// registry mutex held.
+func (r *filterRegistry) expire() {
+	now := r.now()
+	for id, f := range r.filters {
+		if now.Sub(f.lastPoll) > r.timeout {
+			delete(r.filters, id)
+		}
+	}
+}
+
+func (req *EthRPCService) NewFilter(r *http.Request, criteria *FilterCriteria, reply *string) error {
+	fmt.Println("Recieved a request for NewFilter")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	var nextBlock uint64
+	if from := criteria.FromBlock; from == nil || from.Tag == types.LatestBlock || from.Tag == types.PendingBlock {
+		// Only report logs of blocks committed after the filter was installed
+		latest, err := req.latestBlockNumber(user)
+		if err != nil {
+			return err
+		}
+		nextBlock = latest + 1
+	} else {
+		var err error
+		nextBlock, err = req.parseBlockNumber(user, criteria.FromBlock)
+		if err != nil {
+			return err
+		}
+	}
+
+	id, err := req.filters.add(&filter{
+		kind:      logFilter,
+		criteria:  *criteria,
+		nextBlock: nextBlock,
+	})
+	if err != nil {
+		return err
+	}
+	*reply = id
+
+	fmt.Println("Returning from NewFilter, returning filter id: ", id)
+
+	return nil
+}
+
+func (req *EthRPCService) NewBlockFilter(r *http.Request, _ *DataParam, reply *string) error {
+	fmt.Println("Recieved a request for NewBlockFilter")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	latest, err := req.latestBlockNumber(user)
+	if err != nil {
+		return err
+	}
+
+	id, err := req.filters.add(&filter{
+		kind:      blockFilter,
+		nextBlock: latest + 1,
+	})
+	if err != nil {
+		return err
+	}
+	*reply = id
+
+	fmt.Println("Returning from NewBlockFilter, returning filter id: ", id)
+
+	return nil
+}
+
+// GetFilterChanges returns the hashes of the blocks committed since the last
+// poll for block filters, and the matching logs of those blocks for log
+// filters
+func (req *EthRPCService) GetFilterChanges(r *http.Request, id *DataParam, reply *[]interface{}) error {
+	fmt.Println("Recieved a request for GetFilterChanges")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	f, ok := req.filters.get(string(*id))
+	if !ok {
+		return errors.New("filter not found")
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	changes, err := f.poll(req, user)
+	if err != nil {
+		return err
+	}
+	*reply = changes
+
+	fmt.Printf("Returning from GetFilterChanges, returning %d changes\n", len(changes))
+
+	return nil
+}
+
+func (req *EthRPCService) UninstallFilter(r *http.Request, id *DataParam, reply *bool) error {
+	fmt.Println("Recieved a request for UninstallFilter")
+
+	*reply = req.filters.remove(string(*id))
+
+	return nil
+}
+
+// poll collects the changes of the blocks committed since the last poll and
+// advances the filter past them. It must be called with the filter locked.
+func (f *filter) poll(req *EthRPCService, user string) ([]interface{}, error) {
+	latest, err := req.latestBlockNumber(user)
+	if err != nil {
+		return nil, err
+	}
+
+	last := latest
+	if f.kind == logFilter && f.criteria.ToBlock != nil {
+		to, err := req.parseBlockNumber(user, f.criteria.ToBlock)
+		if err != nil {
+			return nil, err
+		}
+		if to < last {
+			last = to
+		}
+	}
+
+	changes := []interface{}{}
+	for ; f.nextBlock <= last; f.nextBlock++ {
+		block, err := req.backend.GetBlockByNumber(req.channel, user, f.nextBlock)
+		if err != nil {
+			return nil, err
+		}
+
+		if f.kind == blockFilter {
+			changes = append(changes, types.BytesToHash(block.GetHeader().Hash()))
+			continue
+		}
+
+		logs, err := blockLogs(block, req.chaincode.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, log := range logs {
+			if f.criteria.Matches(log) {
+				changes = append(changes, log)
+			}
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("filterRegistry", func() {
+	var (
+		registry *filterRegistry
+		now      time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Unix(1500000000, 0)
+		registry = newFilterRegistry(time.Minute)
+		registry.now = func() time.Time { return now }
+	})
+
+	It("uninstalls filters that are not polled within the timeout", func() {
+		id, err := registry.add(&filter{kind: blockFilter})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, ok := registry.get(id)
+		Expect(ok).To(BeTrue())
+
+		now = now.Add(time.Minute + time.Second)
+		_, ok = registry.get(id)
+		Expect(ok).To(BeFalse())
+		Expect(registry.remove(id)).To(BeFalse())
+	})
+
+	It("keeps the filters that are polled", func() {
+		id, err := registry.add(&filter{kind: blockFilter})
+		Expect(err).ToNot(HaveOccurred())
+
+		for i := 0; i < 5; i++ {
+			now = now.Add(50 * time.Second)
+			_, ok := registry.get(id)
+			Expect(ok).To(BeTrue())
+		}
+	})
+
+	It("only expires the idle filters", func() {
+		idle, err := registry.add(&filter{kind: blockFilter})
+		Expect(err).ToNot(HaveOccurred())
+		now = now.Add(time.Minute + time.Second)
+
+		fresh, err := registry.add(&filter{kind: logFilter})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, ok := registry.get(fresh)
+		Expect(ok).To(BeTrue())
+		Expect(registry.filters).ToNot(HaveKey(idle))
+	})
+
+	It("gives every filter its own id", func() {
+		ids := map[string]bool{}
+		for i := 0; i < 10; i++ {
+			id, err := registry.add(&filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(id).To(MatchRegexp("^0x[0-9a-f]{32}$"))
+			ids[id] = true
+		}
+		Expect(ids).To(HaveLen(10))
+	})
+})
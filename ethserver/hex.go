@@ -0,0 +1,24 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+)
+
+// txHash returns the ethereum transaction hash of a fabric transaction. The
+// ID of a fabric transaction is the hex encoding of a sha256 hash, so it has
+// the length of an ethereum transaction hash.
+func txHash(txID string) (types.Hash, error) {
+	hash, err := types.HexToHash(txID)
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("unexpected fabric transaction ID: %s", err)
+	}
+	return hash, nil
+}
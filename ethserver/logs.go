@@ -0,0 +1,341 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// EVMLog is a single log entry as recorded by the evmscc. The evmscc sets the
+// payload of its chaincode event to a JSON array of the logs emitted while
+// executing the transaction.
+type EVMLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+type Log struct {
+	Address          types.Address  `json:"address"`
+	Topics           []types.Hash   `json:"topics"`
+	Data             types.Bytes    `json:"data"`
+	BlockNumber      types.Quantity `json:"blockNumber"`
+	BlockHash        types.Hash     `json:"blockHash"`
+	TransactionHash  types.Hash     `json:"transactionHash"`
+	TransactionIndex types.Quantity `json:"transactionIndex"`
+	LogIndex         types.Quantity `json:"logIndex"`
+}
+
+// FilterCriteria selects logs by block range, emitting contract and topics.
+// Each entry of Topics lists the accepted values for the topic at that
+// position; an empty entry matches any topic. A nil block stands for the
+// latest block.
+type FilterCriteria struct {
+	FromBlock *types.BlockNumberOrTag
+	ToBlock   *types.BlockNumberOrTag
+	Addresses []types.Address
+	Topics    [][]types.Hash
+}
+
+func (f *FilterCriteria) UnmarshalJSON(data []byte) error {
+	var criteria struct {
+		FromBlock *types.BlockNumberOrTag `json:"fromBlock"`
+		ToBlock   *types.BlockNumberOrTag `json:"toBlock"`
+		Address   json.RawMessage         `json:"address"`
+		Topics    []json.RawMessage       `json:"topics"`
+	}
+
+	err := json.Unmarshal(data, &criteria)
+	if err != nil {
+		return err
+	}
+
+	f.FromBlock = criteria.FromBlock
+	f.ToBlock = criteria.ToBlock
+
+	f.Addresses = nil
+	err = unmarshalOneOrMany(criteria.Address, func(data json.RawMessage) error {
+		var address types.Address
+		if err := json.Unmarshal(data, &address); err != nil {
+			return err
+		}
+		f.Addresses = append(f.Addresses, address)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f.Topics = make([][]types.Hash, len(criteria.Topics))
+	for i, topic := range criteria.Topics {
+		err = unmarshalOneOrMany(topic, func(data json.RawMessage) error {
+			var hash types.Hash
+			if err := json.Unmarshal(data, &hash); err != nil {
+				return err
+			}
+			f.Topics[i] = append(f.Topics[i], hash)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmarshalOneOrMany calls add with each value of data, which is either
+// null, a single value or an array of values
+func unmarshalOneOrMany(data json.RawMessage, add func(json.RawMessage) error) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return add(data)
+	}
+
+	for _, value := range list {
+		if err := add(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches returns whether the log satisfies the address and topic criteria.
+// The block range is not taken into account.
+func (f *FilterCriteria) Matches(log Log) bool {
+	if len(f.Addresses) > 0 && !containsAddress(f.Addresses, log.Address) {
+		return false
+	}
+
+	if len(f.Topics) > len(log.Topics) {
+		return false
+	}
+
+	for i, topics := range f.Topics {
+		if len(topics) > 0 && !containsHash(topics, log.Topics[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsAddress(list []types.Address, value types.Address) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsHash(list []types.Hash, value types.Hash) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (req *EthRPCService) GetLogs(r *http.Request, criteria *FilterCriteria, reply *[]Log) error {
+	fmt.Println("Recieved a request for GetLogs")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	from, err := req.parseBlockNumber(user, criteria.FromBlock)
+	if err != nil {
+		return err
+	}
+
+	to, err := req.parseBlockNumber(user, criteria.ToBlock)
+	if err != nil {
+		return err
+	}
+
+	logs := []Log{}
+	for number := from; number <= to; number++ {
+		block, err := req.backend.GetBlockByNumber(req.channel, user, number)
+		if err != nil {
+			return err
+		}
+
+		blkLogs, err := blockLogs(block, req.chaincode.Name)
+		if err != nil {
+			return err
+		}
+
+		for _, log := range blkLogs {
+			if criteria.Matches(log) {
+				logs = append(logs, log)
+			}
+		}
+	}
+	*reply = logs
+
+	fmt.Printf("Returning from GetLogs, returning %d logs\n", len(logs))
+
+	return nil
+}
+
+// blockLogs returns the logs emitted by the valid transactions of the EVM
+// chaincode in a block, in the order they were emitted
+func blockLogs(block *common.Block, chaincode string) ([]Log, error) {
+	blkHeader := block.GetHeader()
+	blockNumber := types.Quantity(blkHeader.GetNumber())
+	blockHash := types.BytesToHash(blkHeader.Hash())
+
+	var txFilter []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	logs := []Log{}
+	for i, envBytes := range block.GetData().GetData() {
+		if i < len(txFilter) && peer.TxValidationCode(txFilter[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		chHeader, err := getChannelHeader(envBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if chHeader.GetType() != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+			continue
+		}
+
+		env := &common.Envelope{}
+		err = proto.Unmarshal(envBytes, env)
+		if err != nil {
+			return nil, err
+		}
+
+		_, invokeSpec, respPayload, err := decodeTransaction(env)
+		if err != nil {
+			return nil, err
+		}
+
+		evmLogs, err := getEVMLogs(respPayload, chaincode)
+		if err != nil {
+			return nil, err
+		}
+		if len(evmLogs) == 0 {
+			continue
+		}
+
+		hash, err := transactionHash(invokeSpec, chHeader.GetTxId())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, evmLog := range evmLogs {
+			log, err := evmLog.toLog()
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s log of transaction %s: %s", chaincode, chHeader.GetTxId(), err)
+			}
+
+			log.BlockNumber = blockNumber
+			log.BlockHash = blockHash
+			log.TransactionHash = hash
+			log.TransactionIndex = types.Quantity(i)
+			log.LogIndex = types.Quantity(len(logs))
+			logs = append(logs, log)
+		}
+	}
+
+	return logs, nil
+}
+
+// toLog decodes the values of the evmscc log
+func (l EVMLog) toLog() (Log, error) {
+	address, err := types.HexToAddress(l.Address)
+	if err != nil {
+		return Log{}, err
+	}
+
+	topics := make([]types.Hash, len(l.Topics))
+	for i, topic := range l.Topics {
+		topics[i], err = types.HexToHash(topic)
+		if err != nil {
+			return Log{}, err
+		}
+	}
+
+	data, err := types.HexToBytes(l.Data)
+	if err != nil {
+		return Log{}, err
+	}
+
+	return Log{Address: address, Topics: topics, Data: data}, nil
+}
+
+// getEVMLogs extracts the logs carried in the chaincode event set by the EVM
+// chaincode. Transactions of other chaincodes, or that did not log anything,
+// yield no logs.
+func getEVMLogs(respPayload *peer.ChaincodeAction, chaincode string) ([]EVMLog, error) {
+	if respPayload == nil || len(respPayload.GetEvents()) == 0 {
+		return nil, nil
+	}
+
+	ccEvent := &peer.ChaincodeEvent{}
+	err := proto.Unmarshal(respPayload.GetEvents(), ccEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	if ccEvent.GetChaincodeId() != chaincode || len(ccEvent.GetPayload()) == 0 {
+		return nil, nil
+	}
+
+	var evmLogs []EVMLog
+	err = json.Unmarshal(ccEvent.GetPayload(), &evmLogs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s logs: %s", chaincode, err)
+	}
+
+	return evmLogs, nil
+}
+
+// logsBloom computes the 2048 bit bloom filter of the addresses and topics of
+// the logs the way ethereum does: each value sets the three bits selected by
+// the first three pairs of bytes of its keccak256 hash
+func logsBloom(logs []Log) types.Bytes {
+	bloom := make(types.Bytes, 256)
+
+	add := func(value []byte) {
+		hash := keccak256(value)
+
+		for i := 0; i < 6; i += 2 {
+			bit := (uint(hash[i])<<8 | uint(hash[i+1])) & 2047
+			bloom[len(bloom)-1-int(bit/8)] |= 1 << (bit % 8)
+		}
+	}
+
+	for _, log := range logs {
+		add(log.Address[:])
+		for _, topic := range log.Topics {
+			add(topic[:])
+		}
+	}
+
+	return bloom
+}
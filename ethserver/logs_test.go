@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("logsBloom", func() {
+	It("is empty without logs", func() {
+		Expect(logsBloom(nil)).To(Equal(types.Bytes(make([]byte, 256))))
+	})
+
+	It("sets the bits selected by the keccak256 hash of the values", func() {
+		// keccak256 of the zero address is 5380c7b7ae81..., which selects
+		// the bits 0x380, 0x7b7 and 0x681 of the bloom
+		bloom := make(types.Bytes, 256)
+		bloom[255-0x380/8] = 0x01
+		bloom[255-0x7b7/8] = 0x80
+		bloom[255-0x681/8] = 0x02
+
+		Expect(logsBloom([]Log{{Address: types.Address{}}})).To(Equal(bloom))
+		Expect(hex.EncodeToString(keccak256(make([]byte, 20)))).To(HavePrefix("5380c7b7ae81"))
+	})
+})
+
+var _ = Describe("FilterCriteria", func() {
+	var (
+		contract = types.Address{0x01}
+		other    = types.Address{0x02}
+		transfer = types.Hash{0xdd}
+		approval = types.Hash{0x8c}
+		owner    = types.Hash{0x0a}
+
+		log = Log{Address: contract, Topics: []types.Hash{transfer, owner}}
+	)
+
+	criteria := func(s string) *FilterCriteria {
+		f := &FilterCriteria{}
+		Expect(json.Unmarshal([]byte(s), f)).To(Succeed())
+		return f
+	}
+
+	It("matches every log without criteria", func() {
+		Expect(criteria(`{}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"address":null,"topics":[]}`).Matches(log)).To(BeTrue())
+	})
+
+	It("matches a single address or any of a list of addresses", func() {
+		Expect(criteria(`{"address":"` + contract.String() + `"}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"address":"` + other.String() + `"}`).Matches(log)).To(BeFalse())
+		Expect(criteria(`{"address":["` + other.String() + `","` + contract.String() + `"]}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"address":["` + other.String() + `"]}`).Matches(log)).To(BeFalse())
+	})
+
+	It("matches topics by position", func() {
+		Expect(criteria(`{"topics":["` + transfer.String() + `"]}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"topics":["` + transfer.String() + `","` + owner.String() + `"]}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"topics":["` + owner.String() + `"]}`).Matches(log)).To(BeFalse())
+		Expect(criteria(`{"topics":["` + owner.String() + `","` + transfer.String() + `"]}`).Matches(log)).To(BeFalse())
+	})
+
+	It("matches any topic at a null position", func() {
+		Expect(criteria(`{"topics":[null,"` + owner.String() + `"]}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"topics":[null,"` + transfer.String() + `"]}`).Matches(log)).To(BeFalse())
+	})
+
+	It("matches any of the alternatives at a position", func() {
+		Expect(criteria(`{"topics":[["` + approval.String() + `","` + transfer.String() + `"]]}`).Matches(log)).To(BeTrue())
+		Expect(criteria(`{"topics":[["` + approval.String() + `"]]}`).Matches(log)).To(BeFalse())
+	})
+
+	It("does not match logs with fewer topics than the criteria", func() {
+		Expect(criteria(`{"topics":[null,null,null]}`).Matches(log)).To(BeFalse())
+	})
+
+	It("requires both the address and the topics to match", func() {
+		Expect(criteria(`{"address":"` + contract.String() + `","topics":["` + approval.String() + `"]}`).Matches(log)).To(BeFalse())
+		Expect(criteria(`{"address":"` + other.String() + `","topics":["` + transfer.String() + `"]}`).Matches(log)).To(BeFalse())
+	})
+
+	It("rejects malformed criteria", func() {
+		f := &FilterCriteria{}
+		Expect(json.Unmarshal([]byte(`{"address":"0x1234"}`), f)).To(MatchError(`invalid address "0x1234": expected 20 bytes, got 2`))
+		Expect(json.Unmarshal([]byte(`{"topics":[["0x1234"]]}`), f)).To(MatchError(`invalid hash "0x1234": expected 32 bytes, got 2`))
+	})
+})
@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	"golang.org/x/crypto/sha3"
+)
+
+// rawTransaction is a signed legacy ethereum transaction, as sent to
+// eth_sendRawTransaction. The signature may follow EIP-155, in which case
+// the chain ID is part of the signed data.
+type rawTransaction struct {
+	fields [][]byte
+	// to is nil for contract deployments
+	to   *types.Address
+	data []byte
+	v    *big.Int
+	r    *big.Int
+	s    *big.Int
+}
+
+var secp256k1HalfN = new(big.Int).Rsh(btcec.S256().N, 1)
+
+// decodeRawTransaction decodes the RLP list
+// [nonce, gasPrice, gas, to, value, data, v, r, s]
+func decodeRawTransaction(raw []byte) (*rawTransaction, error) {
+	items, err := rlpDecodeList(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) != 9 {
+		return nil, fmt.Errorf("expected 9 transaction fields, got %d", len(items))
+	}
+
+	tx := &rawTransaction{
+		fields: items[:6],
+		data:   items[5],
+		v:      new(big.Int).SetBytes(items[6]),
+		r:      new(big.Int).SetBytes(items[7]),
+		s:      new(big.Int).SetBytes(items[8]),
+	}
+
+	switch len(items[3]) {
+	case 0:
+	case types.AddressLength:
+		to := types.BytesToAddress(items[3])
+		tx.to = &to
+	default:
+		return nil, fmt.Errorf("invalid recipient address of %d bytes", len(items[3]))
+	}
+
+	return tx, nil
+}
+
+// sender recovers the address that signed the transaction
+func (tx *rawTransaction) sender() (types.Address, error) {
+	curve := btcec.S256()
+	if tx.r.Sign() == 0 || tx.r.Cmp(curve.N) >= 0 || tx.s.Sign() == 0 || tx.s.Cmp(secp256k1HalfN) > 0 {
+		return types.Address{}, errors.New("invalid transaction signature")
+	}
+
+	// Unprotected transactions sign the first six fields, EIP-155 ones
+	// append the chain ID and two empty fields, and fold the chain ID in v
+	signed := tx.fields
+	var recoveryID uint64
+	switch v := tx.v.Uint64(); {
+	case !tx.v.IsUint64():
+		return types.Address{}, errors.New("invalid transaction signature")
+	case v == 27 || v == 28:
+		recoveryID = v - 27
+	case v >= 35:
+		chainID, _ := tx.chainID()
+		recoveryID = (v - 35) % 2
+		signed = append(append([][]byte{}, tx.fields...), new(big.Int).SetUint64(chainID).Bytes(), nil, nil)
+	default:
+		return types.Address{}, fmt.Errorf("invalid transaction signature v value %d", v)
+	}
+
+	hash := keccak256(rlpEncodeList(signed))
+
+	sig := make([]byte, 65)
+	sig[0] = byte(27 + recoveryID)
+	copy(sig[33-len(tx.r.Bytes()):33], tx.r.Bytes())
+	copy(sig[65-len(tx.s.Bytes()):], tx.s.Bytes())
+
+	pubKey, _, err := btcec.RecoverCompact(curve, sig, hash)
+	if err != nil {
+		return types.Address{}, fmt.Errorf("failed to recover the transaction sender: %s", err)
+	}
+
+	return types.BytesToAddress(keccak256(pubKey.SerializeUncompressed()[1:])), nil
+}
+
+// nonce returns the nonce of the transaction
+func (tx *rawTransaction) nonce() (uint64, error) {
+	if len(tx.fields[0]) > 8 {
+		return 0, errors.New("nonce exceeds 64 bits")
+	}
+	return new(big.Int).SetBytes(tx.fields[0]).Uint64(), nil
+}
+
+// chainID returns the chain ID an EIP-155 transaction was signed for. It
+// returns false for transactions without replay protection.
+func (tx *rawTransaction) chainID() (uint64, bool) {
+	if !tx.v.IsUint64() || tx.v.Uint64() < 35 {
+		return 0, false
+	}
+	return (tx.v.Uint64() - 35) / 2, true
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("rlp", func() {
+	It("round trips lists of strings", func() {
+		items := [][]byte{nil, {0x01}, {0x80}, []byte("dog"), bytes.Repeat([]byte{0xaa}, 60)}
+
+		decoded, err := rlpDecodeList(rlpEncodeList(items))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(decoded).To(HaveLen(len(items)))
+		for i := range items {
+			Expect(decoded[i]).To(BeEquivalentTo(append([]byte{}, items[i]...)))
+		}
+	})
+
+	It("encodes the examples of the specification", func() {
+		Expect(rlpEncodeList([][]byte{[]byte("cat"), []byte("dog")})).To(Equal([]byte{0xc8, 0x83, 'c', 'a', 't', 0x83, 'd', 'o', 'g'}))
+		Expect(rlpEncodeList(nil)).To(Equal([]byte{0xc0}))
+	})
+
+	It("rejects malformed encodings", func() {
+		for _, data := range []string{
+			"",
+			"83646f67",   // a string instead of a list
+			"c483646f",   // truncated
+			"c3646f6700", // trailing data
+			"c2c100",     // nested list
+			"c28101",     // single byte encoded as a string
+			"f803826361", // long form for a short list
+		} {
+			raw, _ := hex.DecodeString(data)
+			_, err := rlpDecodeList(raw)
+			Expect(err).To(HaveOccurred(), data)
+		}
+	})
+})
+
+var _ = Describe("rawTransaction", func() {
+	It("recovers the sender of an EIP-155 transaction", func() {
+		// The example of EIP-155, signed on chain 1 with the key 0x4646...46
+		raw, err := hex.DecodeString("f86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83")
+		Expect(err).ToNot(HaveOccurred())
+
+		tx, err := decodeRawTransaction(raw)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tx.to).ToNot(BeNil())
+		Expect(*tx.to).To(Equal(types.BytesToAddress(bytes.Repeat([]byte{0x35}, 20))))
+		Expect(tx.data).To(BeEmpty())
+
+		sender, err := tx.sender()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sender.String()).To(Equal("0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f"))
+
+		chainID, ok := tx.chainID()
+		Expect(ok).To(BeTrue())
+		Expect(chainID).To(Equal(uint64(1)))
+	})
+
+	It("recovers the sender of an unprotected transaction", func() {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		Expect(err).ToNot(HaveOccurred())
+
+		fields := [][]byte{{0x01}, {0x01}, {0x52, 0x08}, nil, nil, {0x60, 0x60}}
+		sig, err := btcec.SignCompact(btcec.S256(), key, keccak256(rlpEncodeList(fields)), false)
+		Expect(err).ToNot(HaveOccurred())
+
+		raw := rlpEncodeList(append(fields, []byte{sig[0]}, sig[1:33], sig[33:]))
+
+		tx, err := decodeRawTransaction(raw)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tx.to).To(BeNil())
+		Expect(tx.data).To(Equal([]byte{0x60, 0x60}))
+
+		sender, err := tx.sender()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sender).To(Equal(types.BytesToAddress(keccak256(key.PubKey().SerializeUncompressed()[1:]))))
+
+		_, ok := tx.chainID()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("rejects transactions with the wrong number of fields", func() {
+		_, err := decodeRawTransaction(rlpEncodeList([][]byte{{0x01}, {0x02}}))
+		Expect(err).To(MatchError("expected 9 transaction fields, got 2"))
+	})
+
+	It("rejects invalid recipients", func() {
+		_, err := decodeRawTransaction(rlpEncodeList([][]byte{nil, nil, nil, {0x01, 0x02}, nil, nil, {27}, {0x01}, {0x01}}))
+		Expect(err).To(MatchError("invalid recipient address of 2 bytes"))
+	})
+
+	It("rejects malleable signatures", func() {
+		highS := new(big.Int).Sub(btcec.S256().N, big.NewInt(1)).Bytes()
+		tx, err := decodeRawTransaction(rlpEncodeList([][]byte{nil, nil, nil, nil, nil, nil, {27}, {0x01}, highS}))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = tx.sender()
+		Expect(err).To(MatchError("invalid transaction signature"))
+	})
+
+	It("rejects unknown signature versions", func() {
+		tx, err := decodeRawTransaction(rlpEncodeList([][]byte{nil, nil, nil, nil, nil, nil, {30}, {0x01}, {0x01}}))
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = tx.sender()
+		Expect(err).To(MatchError("invalid transaction signature v value 30"))
+	})
+})
@@ -0,0 +1,130 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The RLP encoding of ethereum transactions is a flat list of strings, which
+// is all the encoding and decoding below supports.
+
+var errNotCanonical = errors.New("rlp: non-canonical encoding")
+
+// rlpDecodeList decodes data as a single list of strings
+func rlpDecodeList(data []byte) ([][]byte, error) {
+	isList, content, rest, err := rlpSplit(data)
+	if err != nil {
+		return nil, err
+	}
+	if !isList {
+		return nil, errors.New("rlp: expected a list")
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("rlp: trailing data after the list")
+	}
+
+	var items [][]byte
+	for len(content) > 0 {
+		var item []byte
+		isList, item, content, err = rlpSplit(content)
+		if err != nil {
+			return nil, err
+		}
+		if isList {
+			return nil, errors.New("rlp: unexpected nested list")
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// rlpSplit splits the first item off data, returning whether it is a list,
+// its content and the data that follows it
+func rlpSplit(data []byte) (bool, []byte, []byte, error) {
+	if len(data) == 0 {
+		return false, nil, nil, errors.New("rlp: unexpected end of data")
+	}
+
+	prefix := data[0]
+	switch {
+	case prefix < 0x80:
+		return false, data[:1], data[1:], nil
+	case prefix < 0xb8:
+		content, rest, err := rlpTake(data[1:], uint64(prefix-0x80))
+		if err == nil && len(content) == 1 && content[0] < 0x80 {
+			err = errNotCanonical
+		}
+		return false, content, rest, err
+	case prefix < 0xc0:
+		content, rest, err := rlpTakeLong(data[1:], int(prefix-0xb7))
+		return false, content, rest, err
+	case prefix < 0xf8:
+		content, rest, err := rlpTake(data[1:], uint64(prefix-0xc0))
+		return true, content, rest, err
+	default:
+		content, rest, err := rlpTakeLong(data[1:], int(prefix-0xf7))
+		return true, content, rest, err
+	}
+}
+
+// rlpTakeLong takes the content of an item whose length is given in the
+// lenOfLen bytes following its prefix
+func rlpTakeLong(data []byte, lenOfLen int) ([]byte, []byte, error) {
+	if len(data) < lenOfLen {
+		return nil, nil, errors.New("rlp: unexpected end of data")
+	}
+	if data[0] == 0 || lenOfLen > 8 {
+		return nil, nil, errNotCanonical
+	}
+
+	var size uint64
+	for _, b := range data[:lenOfLen] {
+		size = size<<8 | uint64(b)
+	}
+	if size < 56 {
+		return nil, nil, errNotCanonical
+	}
+
+	return rlpTake(data[lenOfLen:], size)
+}
+
+func rlpTake(data []byte, size uint64) ([]byte, []byte, error) {
+	if uint64(len(data)) < size {
+		return nil, nil, fmt.Errorf("rlp: item of %d bytes exceeds the %d remaining", size, len(data))
+	}
+	return data[:size], data[size:], nil
+}
+
+// rlpEncodeList encodes the strings as a list
+func rlpEncodeList(items [][]byte) []byte {
+	var content []byte
+	for _, item := range items {
+		if len(item) == 1 && item[0] < 0x80 {
+			content = append(content, item[0])
+			continue
+		}
+		content = append(content, rlpHeader(0x80, len(item))...)
+		content = append(content, item...)
+	}
+
+	return append(rlpHeader(0xc0, len(content)), content...)
+}
+
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+
+	var sizeBytes []byte
+	for s := size; s > 0; s >>= 8 {
+		sizeBytes = append([]byte{byte(s)}, sizeBytes...)
+	}
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
@@ -7,120 +7,295 @@ SPDX-License-Identifier: Apache-2.0
 package ethserver
 
 import (
-	"bytes"
+	"context"
+	"crypto/x509"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
-	"strconv"
-	"strings"
+	"sync"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/rpc/v2"
-	"github.com/hyperledger/fabric-sdk-go/api/apitxn"
-	"github.com/hyperledger/fabric-sdk-go/pkg/config"
-	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
 	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
 	"github.com/hyperledger/fabric/protos/peer"
 )
 
+// EthRPCService serves the ethereum JSON-RPC API on a fabric channel, through
+// the EVM chaincode of the channel. The first of its users is the identity
+// used for requests that do not specify a sender.
 type EthRPCService struct {
-	sdk     *fabsdk.FabricSDK
-	user    string
-	channel string
+	backend   FabricBackend
+	user      string
+	channel   string
+	chaincode Chaincode
+	accounts  *accounts
+	filters   *filterRegistry
+	txs       *txTracker
+	rawTxs    *rawTxIndex
+	// chainID is the chain ID signed transactions must be signed for, if
+	// set
+	chainID uint64
 }
 
+// UserHeader is the HTTP header selecting the fabric identity a request is
+// made with. The identity of a WebSocket connection is selected by the header
+// of its handshake and used for all the requests of the connection.
+//
+// The header is not authenticated: any client that can reach the proxy can
+// act as any of its identities, so the proxy must only be reachable by
+// clients trusted with all of them.
+const UserHeader = "X-Fabric-User"
+
 type DataParam string
+
+// Params is the transaction object of eth_call and eth_sendTransaction. A
+// transaction without a recipient deploys a contract.
 type Params struct {
-	From     string
-	To       string
-	Gas      string
-	GasPrice string
-	Value    string
-	Data     string
-	Nonce    string
+	From     *types.Address  `json:"from"`
+	To       *types.Address  `json:"to"`
+	Gas      *types.Big      `json:"gas"`
+	GasPrice *types.Big      `json:"gasPrice"`
+	Value    *types.Big      `json:"value"`
+	Data     types.Bytes     `json:"data"`
+	Nonce    *types.Quantity `json:"nonce"`
 }
 
 type TxReceipt struct {
-	TransactionHash   string
-	BlockHash         string
-	BlockNumber       string
-	ContractAddress   string
-	GasUsed           int
-	CumulativeGasUsed int
+	TransactionHash   types.Hash     `json:"transactionHash"`
+	TransactionIndex  types.Quantity `json:"transactionIndex"`
+	BlockHash         types.Hash     `json:"blockHash"`
+	BlockNumber       types.Quantity `json:"blockNumber"`
+	From              types.Address  `json:"from"`
+	To                *types.Address `json:"to"`
+	ContractAddress   *types.Address `json:"contractAddress"`
+	GasUsed           types.Quantity `json:"gasUsed"`
+	CumulativeGasUsed types.Quantity `json:"cumulativeGasUsed"`
+	Logs              []Log          `json:"logs"`
+	LogsBloom         types.Bytes    `json:"logsBloom"`
+	// Status is 0x1 for transactions that passed validation and 0x0 for
+	// the ones invalidated by the committing peers
+	Status types.Quantity `json:"status"`
+	// ValidationCode is the name of the fabric validation code of the
+	// transaction, such as MVCC_READ_CONFLICT
+	ValidationCode string `json:"validationCode"`
+}
+
+type Transaction struct {
+	BlockHash        types.Hash     `json:"blockHash"`
+	BlockNumber      types.Quantity `json:"blockNumber"`
+	From             types.Address  `json:"from"`
+	Gas              types.Big      `json:"gas"`
+	GasPrice         types.Big      `json:"gasPrice"`
+	Hash             types.Hash     `json:"hash"`
+	Input            types.Bytes    `json:"input"`
+	Nonce            types.Quantity `json:"nonce"`
+	To               *types.Address `json:"to"`
+	TransactionIndex types.Quantity `json:"transactionIndex"`
+	Value            types.Big      `json:"value"`
 }
 
+// EthServer serves an EthRPCService per channel. Each channel is served at
+// /channel/<name>, and the first one is also served at the root path.
 type EthServer struct {
-	Server   *rpc.Server
+	// Server is the RPC server of the default channel
+	Server     *rpc.Server
+	channels   []string
+	handlers   map[string]http.Handler
+	services   []*EthRPCService
+	httpServer *http.Server
+
+	mutex    sync.Mutex
 	listener net.Listener
+	wsConns  map[*wsConnection]struct{}
 }
 
-var zeroAddress = make([]byte, 20)
-
-func NewEthService(configFile, user, channel string) *EthRPCService {
-	fmt.Println(configFile)
-	c := config.FromFile(configFile)
-	sdk, err := fabsdk.New(c)
-	if err != nil {
-		log.Panic("error creating sdk: ", err)
+func NewEthService(backend FabricBackend, users []string, channel string, chaincode Chaincode) *EthRPCService {
+	var user string
+	if len(users) > 0 {
+		user = users[0]
 	}
 
 	return &EthRPCService{
-		sdk:     sdk,
-		user:    user,
-		channel: channel,
+		backend:   backend,
+		user:      user,
+		channel:   channel,
+		chaincode: chaincode,
+		accounts:  newAccounts(backend, users),
+		filters:   newFilterRegistry(DefaultFilterTimeout),
+		txs:       newTxTracker(DefaultTxRetention, DefaultTxTimeout),
+		rawTxs:    newRawTxIndex(backend, channel, chaincode.Name, DefaultRawTxIndexSize),
 	}
 }
 
-func NewEthServer(eth *EthRPCService) *EthServer {
-	server := rpc.NewServer()
+// NewEthServer creates a server for the services, which must be on distinct
+// channels. The first service is the default one, served at the root path.
+func NewEthServer(eth *EthRPCService, others ...*EthRPCService) *EthServer {
+	s := &EthServer{
+		handlers: make(map[string]http.Handler),
+		services: append([]*EthRPCService{eth}, others...),
+		wsConns:  make(map[*wsConnection]struct{}),
+	}
+
+	for _, service := range s.services {
+		server := rpc.NewServer()
+		server.RegisterCodec(NewRPCCodec(server), "application/json")
+		server.RegisterService(service, "eth")
 
-	server.RegisterCodec(NewRPCCodec(), "application/json")
-	server.RegisterService(eth, "eth")
+		if service == eth {
+			s.Server = server
+		}
 
-	return &EthServer{
-		Server: server,
+		s.channels = append(s.channels, service.channel)
+		s.handlers[service.channel] = s.websocketHandler(service, batchHandler(server))
 	}
-}
 
-func (s *EthServer) Start(port int) {
 	r := mux.NewRouter()
-	r.Handle("/", s.Server)
+	r.Handle("/", s.handlers[eth.channel])
+	r.HandleFunc("/channels", s.listChannels).Methods("GET")
+	r.HandleFunc("/channel/{channel}", s.serveChannel)
 
-	allowedHeaders := handlers.AllowedHeaders([]string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Access-Control-Allow-Origin", "Content-Type"})
+	allowedHeaders := handlers.AllowedHeaders([]string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Access-Control-Allow-Origin", "Content-Type", UserHeader})
 	allowedOrigins := handlers.AllowedOrigins([]string{"*"})
 	allowedMethods := handlers.AllowedMethods([]string{"GET", "POST", "PUT"})
 
+	s.httpServer = &http.Server{
+		Handler: handlers.CORS(allowedHeaders, allowedOrigins, allowedMethods)(r),
+	}
+
+	return s
+}
+
+// Start listens on the port and serves requests until the server is stopped
+func (s *EthServer) Start(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve serves requests on the listener until the server is stopped. It
+// returns nil once the server has been stopped.
+func (s *EthServer) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	s.listener = listener
+	s.mutex.Unlock()
+
 	fmt.Println("Starting the server")
-	http.ListenAndServe(fmt.Sprintf(":%d", port), handlers.CORS(allowedHeaders, allowedOrigins, allowedMethods)(r))
+	err := s.httpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
-func (req *EthRPCService) GetCode(r *http.Request, args *DataParam, reply *string) error {
-	fmt.Println("Recieved a request for GetCode")
+// Addr returns the address the server is listening on, or nil if the server
+// has not been started
+func (s *EthServer) Addr() net.Addr {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop gracefully shuts down the server, waiting for the requests in flight
+// to complete
+func (s *EthServer) Stop() error {
+	return s.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting requests, closes the websocket connections and
+// waits for the requests in flight to complete before releasing the fabric
+// connections of the service. If the context is done before all requests
+// complete, the remaining connections are closed forcibly.
+func (s *EthServer) Shutdown(ctx context.Context) error {
+	fmt.Println("Stopping the server")
 
-	if req.user == "" {
-		return errors.New("No user was set. Please login")
+	// Hijacked websocket connections are not tracked by the http server
+	s.mutex.Lock()
+	for c := range s.wsConns {
+		c.conn.Close()
 	}
+	s.mutex.Unlock()
 
-	chClient, err := req.sdk.NewChannelClient(req.channel, req.user)
+	err := s.httpServer.Shutdown(ctx)
 	if err != nil {
-		log.Panic("error creating client", err)
+		s.httpServer.Close()
+	}
+
+	// The services of the channels usually share their backend, only
+	// close it once
+	closed := make(map[FabricBackend]bool)
+	for _, service := range s.services {
+		if service.backend != nil && closed[service.backend] {
+			continue
+		}
+		closed[service.backend] = true
+
+		if closeErr := service.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
+}
+
+// serveChannel dispatches the request to the service of the channel named in
+// the path
+func (s *EthServer) serveChannel(w http.ResponseWriter, r *http.Request) {
+	handler, ok := s.handlers[mux.Vars(r)["channel"]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
+
+// listChannels responds with the names of the channels served, the default
+// channel first
+func (s *EthServer) listChannels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.channels)
+}
+
+// Close releases the connections the backend holds to the fabric network
+func (req *EthRPCService) Close() error {
+	if req.backend == nil {
+		return nil
 	}
 
-	defer chClient.Close()
+	return req.backend.Close()
+}
+
+func (req *EthRPCService) GetCode(r *http.Request, address *types.Address, reply *string) error {
+	fmt.Println("Recieved a request for GetCode")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
 
-	queryArgs := [][]byte{[]byte(Strip0xFromHex(string(*args)))}
+	queryArgs := [][]byte{[]byte(hex.EncodeToString(address[:]))}
 
-	fmt.Println("About to query the `evmscc`")
-	value, err := Query(chClient, "evmscc", "getCode", queryArgs)
+	fmt.Printf("About to query the `%s`\n", req.chaincode.Name)
+	value, err := req.backend.Query(req.channel, user, req.chaincode.Name, "getCode", queryArgs)
 	if err != nil {
 		fmt.Printf("Failed to query: %s\n", err.Error())
+		return fabricError(err)
 	}
-	fmt.Println("About to query the `evmscc`")
 	*reply = string(value)
 
 	fmt.Println("Returning from GetCode")
@@ -128,204 +303,420 @@ func (req *EthRPCService) GetCode(r *http.Request, args *DataParam, reply *strin
 	return nil
 }
 
-func (req *EthRPCService) Call(r *http.Request, params *Params, reply *string) error {
+func (req *EthRPCService) Call(r *http.Request, params *Params, reply *types.Bytes) error {
 
 	fmt.Println("Received a request for Call")
 	fmt.Printf("Data that is being sent:%s \n\n", params.Data)
-	if req.user == "" {
-		return errors.New("No user was set. Please login")
-	}
-
-	chClient, err := req.sdk.NewChannelClient(req.channel, req.user)
+	user, err := req.sender(r, params.From)
 	if err != nil {
 		return err
 	}
-	defer chClient.Close()
 
-	args := [][]byte{[]byte(Strip0xFromHex(params.Data))}
+	if params.To == nil {
+		return invalidParamsError("missing to address")
+	}
+
+	args := [][]byte{[]byte(hex.EncodeToString(params.Data))}
 
-	fmt.Println("About to query the `evmscc`")
-	value, err := Query(chClient, "evmscc", Strip0xFromHex(params.To), args)
+	fmt.Printf("About to query the `%s`\n", req.chaincode.Name)
+	value, err := req.backend.Query(req.channel, user, req.chaincode.Name, hex.EncodeToString(params.To[:]), args)
 	if err != nil {
 		fmt.Printf("Failed to query: %s\n", err)
-		return err
+		return fabricError(err)
 	}
 
-	*reply = "0x" + hex.EncodeToString(value)
+	*reply = value
 	fmt.Println("Returning from Call")
 
 	return nil
 }
 
-func (req *EthRPCService) SendTransaction(r *http.Request, params *Params, reply *string) error {
+func (req *EthRPCService) SendTransaction(r *http.Request, params *Params, reply *types.Hash) error {
 	fmt.Println("Recieved a request for SendTransaction")
 	fmt.Printf("Data that is being sent:%s \n\n", params.Data)
-	if req.user == "" {
-		return errors.New("No user was set. Please login")
-	}
-	chClient, err := req.sdk.NewChannelClient(req.channel, req.user)
+	user, err := req.sender(r, params.From)
 	if err != nil {
 		return err
 	}
-	defer chClient.Close()
 
-	if params.To == "" {
-		params.To = hex.EncodeToString(zeroAddress)
+	// Contracts are deployed by invoking the zero address
+	var to types.Address
+	if params.To != nil {
+		to = *params.To
 	}
 
-	txReq := apitxn.ExecuteTxRequest{
-		ChaincodeID: "evmscc",
-		Fcn:         Strip0xFromHex(params.To),
-		Args:        [][]byte{[]byte(Strip0xFromHex(params.Data))},
-	}
+	args := [][]byte{[]byte(hex.EncodeToString(params.Data))}
 
 	fmt.Println("About to execute a transaction")
-	//Return only the transaction ID
-	//Maybe change to an async transaction
-	_, txID, err := chClient.ExecuteTx(txReq)
+	// Return as soon as the transaction is endorsed, the receipt is
+	// available once the transaction is committed
+	_, txID, statuses, err := req.backend.ExecuteTx(req.channel, user, req.chaincode.Name, hex.EncodeToString(to[:]), args)
 	if err != nil {
 		fmt.Printf("Failed to execute transaction: %s\n", err)
+		return fabricError(err)
+	}
+
+	hash, err := txHash(txID)
+	if err != nil {
 		return err
 	}
+	req.txs.track(txID, statuses)
 
-	*reply = txID.ID
-	fmt.Println("Returning from SendTransaction, returning txID: ", txID.ID)
+	*reply = hash
+	fmt.Println("Returning from SendTransaction, returning hash: ", hash)
 
 	return nil
 }
 
-func (req *EthRPCService) GetTransactionReceipt(r *http.Request, param *DataParam, reply *TxReceipt) error {
-	fmt.Println("Recieved a request for GetTransactionReceipt")
+// SendRawTransaction submits a transaction signed by an ethereum wallet with
+// the fabric identity the signer's address is registered to. The signed
+// transaction is submitted along with its input, so that the transaction can
+// be found on the ledger by the hash of the signed transaction, which is
+// returned. A signed transaction is only submitted once, its nonce must not
+// have been used by its signer on the channel, and it has to be signed for the
+// chain ID of the service when it has one.
+//
+// Transactions submitted through other proxies are only known once they are
+// committed, so a transaction sent to several proxies at once, or to several
+// channels with the same chain ID, may be submitted more than once.
+func (req *EthRPCService) SendRawTransaction(r *http.Request, raw *types.Bytes, reply *types.Hash) error {
+	fmt.Println("Recieved a request for SendRawTransaction")
+
+	rawTx, err := decodeRawTransaction(*raw)
+	if err != nil {
+		return invalidParamsError("invalid raw transaction: %s", err)
+	}
 
-	if req.user == "" {
-		return errors.New("No user was set. Please login")
+	if req.chainID != 0 {
+		chainID, ok := rawTx.chainID()
+		if !ok {
+			return invalidParamsError("transaction is not replay protected, expected a transaction signed for chain ID %d", req.chainID)
+		}
+		if chainID != req.chainID {
+			return invalidParamsError("transaction signed for chain ID %d, expected chain ID %d", chainID, req.chainID)
+		}
 	}
-	chClient, err := req.sdk.NewChannelClient(req.channel, req.user)
 
-	args := [][]byte{[]byte(req.channel), []byte(*param)}
+	from, err := rawTx.sender()
+	if err != nil {
+		return invalidParamsError("invalid raw transaction: %s", err)
+	}
 
-	t, err := Query(chClient, "qscc", "GetTransactionByID", args)
+	nonce, err := rawTx.nonce()
 	if err != nil {
-		return err
+		return invalidParamsError("invalid raw transaction: %s", err)
 	}
 
-	tx := &peer.ProcessedTransaction{}
-	err = proto.Unmarshal(t, tx)
+	user, ok, err := req.accounts.user(from)
 	if err != nil {
 		return err
 	}
+	if !ok {
+		return invalidParamsError("account %s is not registered to a fabric identity", from)
+	}
 
-	b, err := Query(chClient, "qscc", "GetBlockByTxID", args)
-	if err != nil {
-		fmt.Printf("Failed to query qscc: %s\n", err)
+	// A signed transaction is only submitted once, whether it is still
+	// tracked or already on the ledger, so that it cannot be replayed
+	hash := types.BytesToHash(keccak256(*raw))
+	id := hex.EncodeToString(hash[:])
+	if !req.txs.reserve(id) {
+		return alreadyKnownError(hash)
+	}
+
+	// The index only has to be loaded once, from then on it follows the
+	// new blocks
+	if err := req.rawTxs.wait(user); err != nil {
+		req.txs.release(id)
 		return err
 	}
+	if _, known := req.rawTxs.lookup(hash); known {
+		req.txs.release(id)
+		return alreadyKnownError(hash)
+	}
+
+	next, ok := req.rawTxs.reserveNonce(from, nonce)
+	if !ok {
+		req.txs.release(id)
+		return nonceTooLowError(nonce, next)
+	}
+
+	var to types.Address
+	if rawTx.to != nil {
+		to = *rawTx.to
+	}
+
+	args := [][]byte{[]byte(hex.EncodeToString(rawTx.data)), []byte(hex.EncodeToString(*raw))}
+
+	fmt.Println("About to execute a transaction")
+	_, txID, statuses, err := req.backend.ExecuteTx(req.channel, user, req.chaincode.Name, hex.EncodeToString(to[:]), args)
+	if err != nil {
+		req.rawTxs.releaseNonce(from, nonce, next)
+		req.txs.release(id)
+		fmt.Printf("Failed to execute transaction: %s\n", err)
+		return fabricError(err)
+	}
+
+	req.txs.track(txID, statuses)
+	req.txs.alias(id, txID)
+
+	*reply = hash
+	fmt.Println("Returning from SendRawTransaction, returning hash: ", hash)
+
+	return nil
+}
+
+// SetChainID makes the service only accept signed transactions that are
+// replay protected with the chain ID, as defined by EIP-155. Without a chain
+// ID any signed transaction is accepted. The services of different channels
+// must have different chain IDs, otherwise a transaction signed for one
+// channel can be replayed on the others.
+func (req *EthRPCService) SetChainID(chainID uint64) {
+	req.chainID = chainID
+}
+
+// RegisterAccount maps the address of an ethereum key to one of the fabric
+// identities of the service, so that transactions signed with the key are
+// submitted with that identity
+func (req *EthRPCService) RegisterAccount(address, user string) error {
+	addr, err := types.HexToAddress(address)
+	if err != nil {
+		return fmt.Errorf("invalid account address %s", address)
+	}
+	if !req.accounts.holds(user) {
+		return fmt.Errorf("unknown user %s", user)
+	}
+
+	req.accounts.register(addr, user)
+	return nil
+}
+
+// GetTransactionReceipt returns a null receipt for a transaction that is not
+// committed yet, whether it was submitted through the service or not
+func (req *EthRPCService) GetTransactionReceipt(r *http.Request, hash *types.Hash, reply **TxReceipt) error {
+	fmt.Println("Recieved a request for GetTransactionReceipt")
 
-	block := &common.Block{}
-	err = proto.Unmarshal(b, block)
+	user, err := req.requestUser(r)
 	if err != nil {
 		return err
 	}
 
-	blkHeader := block.GetHeader()
+	txID := req.resolveTxID(user, *hash)
 
-	p := tx.GetTransactionEnvelope().GetPayload()
-	payload := &common.Payload{}
-	err = proto.Unmarshal(p, payload)
+	tracked, ok := req.txs.get(txID)
+	if ok && tracked.pending {
+		fmt.Println("Returning from GetTransactionReceipt, transaction is pending")
+		*reply = nil
+		return nil
+	}
+
+	tx, block, err := req.getTransaction(user, txID)
 	if err != nil {
+		// Invalid transactions are recorded on the ledger too, so the
+		// transaction only fails to show up if it was never committed
+		if ok && tracked.status.Err != nil {
+			return fmt.Errorf("transaction %s was not committed: %s", hash, tracked.status.Err)
+		}
+		if err == ErrTxNotFound {
+			fmt.Println("Returning from GetTransactionReceipt, transaction is not committed")
+			*reply = nil
+			return nil
+		}
 		return err
 	}
 
-	txActions := &peer.Transaction{}
-	err = proto.Unmarshal(payload.GetData(), txActions)
+	blkHeader := block.GetHeader()
 
+	payload, invokeSpec, respPayload, err := decodeTransaction(tx.GetTransactionEnvelope())
 	if err != nil {
 		return err
 	}
 
-	actions := txActions.GetActions()
+	from, err := creatorAddress(payload)
+	if err != nil {
+		return err
+	}
 
-	ccPropPayload, respPayload, err := GetPayloads(actions[0])
+	index, err := findTransactionIndex(block, txID)
 	if err != nil {
 		return err
 	}
 
-	invokeSpec := &peer.ChaincodeInvocationSpec{}
-	err = proto.Unmarshal(ccPropPayload.Input, invokeSpec)
+	ethHash, err := transactionHash(invokeSpec, txID)
 	if err != nil {
 		return err
 	}
 
+	validationCode := peer.TxValidationCode(tx.GetValidationCode())
+
 	receipt := TxReceipt{
-		TransactionHash:   string(*param),
-		BlockHash:         hex.EncodeToString(blkHeader.Hash()),
-		BlockNumber:       strconv.FormatUint(blkHeader.GetNumber(), 10),
-		GasUsed:           0,
-		CumulativeGasUsed: 0,
+		TransactionHash:  ethHash,
+		TransactionIndex: types.Quantity(index),
+		BlockHash:        types.BytesToHash(blkHeader.Hash()),
+		BlockNumber:      types.Quantity(blkHeader.GetNumber()),
+		From:             from,
+		Status:           1,
+		ValidationCode:   validationCode.String(),
+	}
+
+	if validationCode != peer.TxValidationCode_VALID {
+		receipt.Status = 0
+	}
+
+	args := invokeSpec.GetChaincodeSpec().GetInput().Args
+	if len(args) == 0 {
+		return fmt.Errorf("transaction %s has no callee address", hash)
 	}
 
-	args = invokeSpec.GetChaincodeSpec().GetInput().Args
 	// First arg is the callee address. If it is zero address, tx was a contract creation
-	callee, err := hex.DecodeString(string(args[0]))
+	callee, err := types.HexToAddress(string(args[0]))
 	if err != nil {
 		return err
 	}
 
-	if bytes.Equal(callee, zeroAddress) {
-		receipt.ContractAddress = string(respPayload.GetResponse().GetPayload())
+	if callee == (types.Address{}) {
+		// An invalidated deployment did not create the contract
+		if validationCode == peer.TxValidationCode_VALID {
+			contractAddress, err := types.HexToAddress(string(respPayload.GetResponse().GetPayload()))
+			if err != nil {
+				return err
+			}
+			receipt.ContractAddress = &contractAddress
+		}
+	} else {
+		receipt.To = &callee
+	}
+
+	logs, err := blockLogs(block, req.chaincode.Name)
+	if err != nil {
+		return err
 	}
-	*reply = receipt
+
+	receipt.Logs = []Log{}
+	for _, txLog := range logs {
+		if txLog.TransactionHash == receipt.TransactionHash {
+			receipt.Logs = append(receipt.Logs, txLog)
+		}
+	}
+	receipt.LogsBloom = logsBloom(receipt.Logs)
+	*reply = &receipt
 
 	fmt.Println("Returning from GetTransactionReceipt, returing receipt: ", receipt)
 
 	return nil
 }
 
-func (req *EthRPCService) Accounts(r *http.Request, params *DataParam, reply *[]string) error {
-	fmt.Println("Recieved a request for Accounts")
+// GetTransactionByHash returns a null transaction for a transaction that is
+// not committed yet, whether it was submitted through the service or not
+func (req *EthRPCService) GetTransactionByHash(r *http.Request, hash *types.Hash, reply **Transaction) error {
+	fmt.Println("Recieved a request for GetTransactionByHash")
+
+	user, err := req.requestUser(r)
+	if err != nil {
+		return err
+	}
+
+	txID := req.resolveTxID(user, *hash)
 
-	if req.user == "" {
-		return errors.New("No user was set. Please login")
+	tracked, ok := req.txs.get(txID)
+	if ok && tracked.pending {
+		fmt.Println("Returning from GetTransactionByHash, transaction is pending")
+		*reply = nil
+		return nil
 	}
-	chClient, err := req.sdk.NewChannelClient(req.channel, req.user)
+
+	tx, block, err := req.getTransaction(user, txID)
+	if err != nil {
+		if ok && tracked.status.Err != nil {
+			return fmt.Errorf("transaction %s was not committed: %s", hash, tracked.status.Err)
+		}
+		if err == ErrTxNotFound {
+			fmt.Println("Returning from GetTransactionByHash, transaction is not committed")
+			*reply = nil
+			return nil
+		}
+		return err
+	}
+
+	index, err := findTransactionIndex(block, txID)
+	if err != nil {
+		return err
+	}
+
+	transaction, err := newTransaction(block, index, txID, tx.GetTransactionEnvelope())
 	if err != nil {
-		log.Panic("error creating client", err)
 		return err
 	}
 
-	defer chClient.Close()
+	*reply = &transaction
+
+	fmt.Println("Returning from GetTransactionByHash, returning transaction: ", transaction)
+
+	return nil
+}
 
-	queryArgs := [][]byte{}
+// Accounts returns the address of the identity selected by the user header,
+// or the addresses of all the identities of the proxy without the header
+func (req *EthRPCService) Accounts(r *http.Request, params *DataParam, reply *[]types.Address) error {
+	fmt.Println("Recieved a request for Accounts")
 
-	fmt.Println("About to query the `evmscc`")
-	value, err := Query(chClient, "evmscc", "account", queryArgs)
+	user, err := req.requestUser(r)
 	if err != nil {
-		fmt.Printf("Failed to query: %s\n", err)
 		return err
 	}
-	fmt.Println("About to query the `evmscc`")
-	*reply = []string{"0x" + strings.ToLower(string(value))}
+
+	var addresses []types.Address
+	if r != nil && r.Header.Get(UserHeader) != "" {
+		var address types.Address
+		address, err = req.accounts.address(user)
+		addresses = []types.Address{address}
+	} else {
+		addresses, err = req.accounts.list()
+	}
+	if err != nil {
+		fmt.Printf("Failed to get the identities: %s\n", err)
+		return err
+	}
+	*reply = addresses
 
 	fmt.Println("Returning from Accounts")
 
 	return nil
 }
 
-func Query(chClient apitxn.ChannelClient, chaincodeID string, function string, queryArgs [][]byte) ([]byte, error) {
+// requestUser returns the identity selected by the user header of the
+// request, or the default user when the header is not set
+func (req *EthRPCService) requestUser(r *http.Request) (string, error) {
+	user := req.user
+	if r != nil && r.Header.Get(UserHeader) != "" {
+		user = r.Header.Get(UserHeader)
+		if !req.accounts.holds(user) {
+			return "", invalidParamsError("unknown user %s", user)
+		}
+	}
+
+	if user == "" {
+		return "", errors.New("No user was set. Please login")
+	}
 
-	return chClient.Query(apitxn.QueryRequest{
-		ChaincodeID: chaincodeID,
-		Fcn:         function,
-		Args:        queryArgs,
-	})
+	return user, nil
 }
 
-func Strip0xFromHex(addr string) string {
-	stripped := strings.Split(addr, "0x")
-	// if len(stripped) != 1 {
-	// 	panic("Had more then 1 0x in address")
-	// }
-	return stripped[len(stripped)-1]
+// sender returns the identity to use for a request sent from the address.
+// Without a sender the identity of the request is used.
+func (req *EthRPCService) sender(r *http.Request, from *types.Address) (string, error) {
+	if from == nil {
+		return req.requestUser(r)
+	}
+
+	user, ok, err := req.accounts.user(*from)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", invalidParamsError("unknown account %s", from)
+	}
+
+	return user, nil
 }
 
 func GetPayloads(txActions *peer.TransactionAction) (*peer.ChaincodeProposalPayload, *peer.ChaincodeAction, error) {
@@ -363,3 +754,210 @@ func GetPayloads(txActions *peer.TransactionAction) (*peer.ChaincodeProposalPayl
 	}
 	return ccProposalPayload, respPayload, nil
 }
+
+// resolveTxID returns the ID of the fabric transaction of a hash, which is
+// either the hash of a signed ethereum transaction or the fabric transaction
+// ID. Signed transactions still waiting to be committed are only known to the
+// tracker, the committed ones are found by the index of the ledger. The index
+// is loaded in the background, until then the hash is taken for a fabric
+// transaction ID.
+func (req *EthRPCService) resolveTxID(user string, hash types.Hash) string {
+	id := hex.EncodeToString(hash[:])
+	if txID := req.txs.resolve(id); txID != id {
+		return txID
+	}
+	if _, ok := req.txs.get(id); ok {
+		return id
+	}
+
+	req.rawTxs.start(user)
+	if txID, ok := req.rawTxs.lookup(hash); ok {
+		return txID
+	}
+	return id
+}
+
+// getTransaction retrieves a committed transaction and the block it was
+// committed in from the qscc
+func (req *EthRPCService) getTransaction(user, txID string) (*peer.ProcessedTransaction, *common.Block, error) {
+	tx, err := req.backend.GetTransactionByID(req.channel, user, txID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := req.backend.GetBlockByTxID(req.channel, user, txID)
+	if err != nil {
+		fmt.Printf("Failed to query qscc: %s\n", err)
+		return nil, nil, err
+	}
+
+	return tx, block, nil
+}
+
+// decodeTransaction unpacks the payload, the chaincode invocation and the
+// chaincode response of an endorser transaction envelope
+func decodeTransaction(env *common.Envelope) (*common.Payload, *peer.ChaincodeInvocationSpec, *peer.ChaincodeAction, error) {
+	payload := &common.Payload{}
+	err := proto.Unmarshal(env.GetPayload(), payload)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	txActions := &peer.Transaction{}
+	err = proto.Unmarshal(payload.GetData(), txActions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	actions := txActions.GetActions()
+	if len(actions) == 0 {
+		return nil, nil, nil, errors.New("transaction has no actions")
+	}
+
+	ccPropPayload, respPayload, err := GetPayloads(actions[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	invokeSpec := &peer.ChaincodeInvocationSpec{}
+	err = proto.Unmarshal(ccPropPayload.Input, invokeSpec)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return payload, invokeSpec, respPayload, nil
+}
+
+// findTransactionIndex returns the position of the transaction in the block
+func findTransactionIndex(block *common.Block, txID string) (int, error) {
+	for i, envBytes := range block.GetData().GetData() {
+		chHeader, err := getChannelHeader(envBytes)
+		if err != nil {
+			return 0, err
+		}
+
+		if chHeader.GetTxId() == txID {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("transaction %s not found in block %d", txID, block.GetHeader().GetNumber())
+}
+
+// newTransaction builds the ethereum representation of the evmscc
+// transaction at the given position in the block
+func newTransaction(block *common.Block, index int, txID string, env *common.Envelope) (Transaction, error) {
+	payload, invokeSpec, _, err := decodeTransaction(env)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	from, err := creatorAddress(payload)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	hash, err := transactionHash(invokeSpec, txID)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	blkHeader := block.GetHeader()
+	transaction := Transaction{
+		BlockHash:        types.BytesToHash(blkHeader.Hash()),
+		BlockNumber:      types.Quantity(blkHeader.GetNumber()),
+		From:             from,
+		Hash:             hash,
+		Input:            types.Bytes{},
+		TransactionIndex: types.Quantity(index),
+	}
+
+	// The evmscc is invoked with the callee address as the function name and
+	// the input data as the first argument
+	args := invokeSpec.GetChaincodeSpec().GetInput().Args
+	if len(args) == 0 {
+		return Transaction{}, fmt.Errorf("transaction %s has no callee address", txID)
+	}
+
+	callee, err := types.HexToAddress(string(args[0]))
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	// A zero callee address means the transaction created a contract, which
+	// Ethereum represents with a null `to`
+	if callee != (types.Address{}) {
+		transaction.To = &callee
+	}
+
+	if len(args) > 1 {
+		transaction.Input, err = types.HexToBytes(string(args[1]))
+		if err != nil {
+			return Transaction{}, err
+		}
+	}
+
+	return transaction, nil
+}
+
+func getChannelHeader(envBytes []byte) (*common.ChannelHeader, error) {
+	env := &common.Envelope{}
+	err := proto.Unmarshal(envBytes, env)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &common.Payload{}
+	err = proto.Unmarshal(env.GetPayload(), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	chHeader := &common.ChannelHeader{}
+	err = proto.Unmarshal(payload.GetHeader().GetChannelHeader(), chHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	return chHeader, nil
+}
+
+// creatorAddress returns the ethereum address of the identity that submitted
+// the transaction
+func creatorAddress(payload *common.Payload) (types.Address, error) {
+	sigHeader := &common.SignatureHeader{}
+	err := proto.Unmarshal(payload.GetHeader().GetSignatureHeader(), sigHeader)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	return identityToAddress(sigHeader.GetCreator())
+}
+
+// identityToAddress derives the ethereum address of a serialized msp identity
+// the same way the evmscc does: the last 20 bytes of the keccak256 hash of the
+// DER encoded public key of the identity's certificate
+func identityToAddress(creator []byte) (types.Address, error) {
+	id := &msp.SerializedIdentity{}
+	err := proto.Unmarshal(creator, id)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	block, _ := pem.Decode(id.GetIdBytes())
+	if block == nil {
+		return types.Address{}, errors.New("identity does not contain a PEM encoded certificate")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	pubKey, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return types.Address{}, err
+	}
+
+	return types.BytesToAddress(keccak256(pubKey)), nil
+}
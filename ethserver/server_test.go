@@ -7,10 +7,37 @@ SPDX-License-Identifier: Apache-2.0
 package ethserver_test
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/gorilla/websocket"
 	"github.com/hyperledger/fabric-chaincode-evm/ethserver"
-	"github.com/onsi/ginkgo/config"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/ethserverfakes"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	"github.com/hyperledger/fabric-sdk-go/pkg/status"
+	sdkpeer "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/msp"
+	"github.com/hyperledger/fabric/protos/peer"
+	"golang.org/x/crypto/sha3"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -20,21 +47,75 @@ var _ = Describe("EthServer", func() {
 	var (
 		server     *ethserver.EthServer
 		serverAddr string
-		port       int
+		listener   net.Listener
+		serveErr   chan error
 	)
+
+	newService := func() *ethserver.EthRPCService {
+		return ethserver.NewEthService(&ethserverfakes.FakeFabricBackend{}, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+	}
+
 	BeforeEach(func() {
-		server = ethserver.NewEthServer(ethserver.NewEthService())
-		port = 5000 + config.GinkgoConfig.ParallelNode
-		go func() {
-			server.Start(port)
-		}()
-		serverAddr = fmt.Sprintf("http://127.0.0.1:%d", port)
+		server = ethserver.NewEthServer(newService())
+
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		errs := make(chan error, 1)
+		go func(server *ethserver.EthServer, listener net.Listener) {
+			errs <- server.Serve(listener)
+		}(server, listener)
+		serveErr = errs
+		serverAddr = fmt.Sprintf("http://%s", listener.Addr().String())
 	})
 
 	AfterEach(func() {
 		server.Stop()
 	})
 
+	Describe("Lifecycle", func() {
+		It("serves requests on the provided listener", func() {
+			jsonRequest := `{"jsonrpc":"2.0","method":"eth_unknownMethod","params":[],"id":1}`
+
+			Eventually(func() error {
+				res, err := http.Post(serverAddr, "application/json", strings.NewReader(jsonRequest))
+				if err == nil {
+					res.Body.Close()
+				}
+				return err
+			}).Should(Succeed())
+
+			res, err := http.Post(serverAddr, "application/json", strings.NewReader(jsonRequest))
+			Expect(err).ToNot(HaveOccurred())
+			defer res.Body.Close()
+
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			body, err := ioutil.ReadAll(res.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).To(MatchJSON(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"the method eth_unknownMethod does not exist/is not available","data":null},"id":1}`))
+		})
+
+		It("reports the address it is listening on", func() {
+			Eventually(server.Addr).ShouldNot(BeNil())
+			Expect(server.Addr().String()).To(Equal(listener.Addr().String()))
+		})
+
+		It("stops serving once stopped", func() {
+			Expect(server.Stop()).To(Succeed())
+			Eventually(serveErr).Should(Receive(BeNil()))
+
+			_, err := http.Post(serverAddr, "application/json", strings.NewReader(`{}`))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("returns an error when the port is unavailable", func() {
+			port := listener.Addr().(*net.TCPAddr).Port
+			otherServer := ethserver.NewEthServer(newService())
+			Expect(otherServer.Start(port)).ToNot(Succeed())
+		})
+	})
+
 	// Describe("WEB3", func() {
 	// 	Context("client version", func() {
 	// 		It("returns the client verstion", func() {
@@ -59,10 +140,1673 @@ var _ = Describe("EthServer", func() {
 	// 	})
 	// })
 
-	XDescribe("ETH", func() {
-		Context("Get Code", func() {
+	Describe("ETH", func() {
+		var (
+			ethservice  *ethserver.EthRPCService
+			fakeBackend *ethserverfakes.FakeFabricBackend
+			addresses   map[string]types.Address
+		)
+
+		BeforeEach(func() {
+			identities := map[string][]byte{}
+			addresses = map[string]types.Address{}
+			for _, user := range []string{"User1", "User2"} {
+				identities[user], addresses[user] = newIdentity()
+			}
+
+			fakeBackend = &ethserverfakes.FakeFabricBackend{}
+			fakeBackend.IdentityStub = func(user string) ([]byte, error) {
+				identity, ok := identities[user]
+				if !ok {
+					return nil, errors.New("user not found")
+				}
+				return identity, nil
+			}
+			ethservice = ethserver.NewEthService(fakeBackend, []string{"User1", "User2"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+		})
+
+		It("requires a user", func() {
+			ethservice = ethserver.NewEthService(fakeBackend, nil, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+
+			var reply string
+			err := ethservice.GetCode(&http.Request{}, mustAddress(contractAddress), &reply)
+			Expect(err).To(MatchError("No user was set. Please login"))
+			Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+		})
+
+		Context("GetCode", func() {
 			It("gets the code associated with the contract", func() {
+				fakeBackend.QueryReturns([]byte("sample-code"), nil)
+
+				var reply string
+				err := ethservice.GetCode(&http.Request{}, mustAddress("0x"+contractAddress), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal("sample-code"))
+
+				Expect(fakeBackend.QueryCallCount()).To(Equal(1))
+				channel, user, chaincodeID, fcn, args := fakeBackend.QueryArgsForCall(0)
+				Expect(channel).To(Equal("channel1"))
+				Expect(user).To(Equal("User1"))
+				Expect(chaincodeID).To(Equal("evmscc"))
+				Expect(fcn).To(Equal("getCode"))
+				Expect(args).To(Equal([][]byte{[]byte(contractAddress)}))
+			})
+
+			It("returns an error when the query fails", func() {
+				fakeBackend.QueryReturns(nil, errors.New("boom!"))
+
+				var reply string
+				err := ethservice.GetCode(&http.Request{}, mustAddress(contractAddress), &reply)
+				Expect(err).To(MatchError("boom!"))
+			})
+		})
+
+		Context("Call", func() {
+			It("queries the contract with the input data", func() {
+				fakeBackend.QueryReturns([]byte{0xab, 0xcd}, nil)
+
+				var reply types.Bytes
+				err := ethservice.Call(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress), Data: types.Bytes{0x56, 0x78}}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal(types.Bytes{0xab, 0xcd}))
+
+				_, _, chaincodeID, fcn, args := fakeBackend.QueryArgsForCall(0)
+				Expect(chaincodeID).To(Equal("evmscc"))
+				Expect(fcn).To(Equal(contractAddress))
+				Expect(args).To(Equal([][]byte{[]byte("5678")}))
+			})
+
+			It("requires a recipient", func() {
+				var reply types.Bytes
+				err := ethservice.Call(&http.Request{}, &ethserver.Params{Data: types.Bytes{0x56, 0x78}}, &reply)
+				Expect(err).To(MatchError("missing to address"))
+				Expect(err.(*json2.Error).Code).To(Equal(ethserver.ErrInvalidParams))
+				Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a sender is given", func() {
+			It("calls the contract as the identity of the sender", func() {
+				var reply types.Bytes
+				err := ethservice.Call(&http.Request{}, &ethserver.Params{From: addressPtr(addresses["User2"]), To: mustAddress(contractAddress)}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, user, _, _, _ := fakeBackend.QueryArgsForCall(0)
+				Expect(user).To(Equal("User2"))
+			})
+
+			It("sends the transaction as the identity of the sender", func() {
+				fakeBackend.ExecuteTxReturns(nil, sentTxID, make(chan ethserver.TxStatus), nil)
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{From: addressPtr(addresses["User2"]), To: mustAddress(contractAddress)}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, user, _, _, _ := fakeBackend.ExecuteTxArgsForCall(0)
+				Expect(user).To(Equal("User2"))
+			})
+
+			It("uses the first identity without a sender", func() {
+				fakeBackend.ExecuteTxReturns(nil, sentTxID, make(chan ethserver.TxStatus), nil)
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, user, _, _, _ := fakeBackend.ExecuteTxArgsForCall(0)
+				Expect(user).To(Equal("User1"))
+			})
+
+			It("rejects unknown senders", func() {
+				var hash types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{From: mustAddress(contractAddress), To: mustAddress(contractAddress)}, &hash)
+				Expect(err).To(MatchError("unknown account 0x" + contractAddress))
+				Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+
+				var reply types.Bytes
+				err = ethservice.Call(&http.Request{}, &ethserver.Params{From: mustAddress(contractAddress), To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(MatchError("unknown account 0x" + contractAddress))
+				Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the user header is set", func() {
+			var r *http.Request
+
+			BeforeEach(func() {
+				r = &http.Request{Header: http.Header{}}
+				r.Header.Set(ethserver.UserHeader, "User2")
+			})
+
+			It("makes the request as the selected identity", func() {
+				var reply string
+				err := ethservice.GetCode(r, mustAddress(contractAddress), &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, user, _, _, _ := fakeBackend.QueryArgsForCall(0)
+				Expect(user).To(Equal("User2"))
+			})
+
+			It("lets the sender take precedence", func() {
+				var reply types.Bytes
+				err := ethservice.Call(r, &ethserver.Params{From: addressPtr(addresses["User1"]), To: mustAddress(contractAddress)}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, user, _, _, _ := fakeBackend.QueryArgsForCall(0)
+				Expect(user).To(Equal("User1"))
+			})
+
+			It("only returns the account of the selected identity", func() {
+				var reply []types.Address
+				err := ethservice.Accounts(r, dataParam(""), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal([]types.Address{addresses["User2"]}))
+			})
+
+			It("rejects identities the proxy does not hold", func() {
+				r.Header.Set(ethserver.UserHeader, "User3")
+
+				var reply string
+				err := ethservice.GetCode(r, mustAddress(contractAddress), &reply)
+				Expect(err).To(MatchError("unknown user User3"))
+				Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+			})
+
+			Context("when served", func() {
+				var (
+					ethServer *ethserver.EthServer
+					addr      string
+				)
+
+				BeforeEach(func() {
+					ethServer = ethserver.NewEthServer(ethservice)
+
+					listener, err := net.Listen("tcp", "127.0.0.1:0")
+					Expect(err).ToNot(HaveOccurred())
+					go ethServer.Serve(listener)
+					addr = listener.Addr().String()
+				})
+
+				AfterEach(func() {
+					ethServer.Stop()
+				})
+
+				It("uses the identity selected by the HTTP header", func() {
+					req, err := http.NewRequest("POST", "http://"+addr, strings.NewReader(`{"jsonrpc":"2.0","method":"eth_getCode","params":["0x`+contractAddress+`"],"id":1}`))
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Content-Type", "application/json")
+					req.Header.Set(ethserver.UserHeader, "User2")
+
+					res, err := http.DefaultClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					res.Body.Close()
+
+					Expect(fakeBackend.QueryCallCount()).To(Equal(1))
+					_, user, _, _, _ := fakeBackend.QueryArgsForCall(0)
+					Expect(user).To(Equal("User2"))
+				})
+
+				It("allows browsers to send the user header", func() {
+					req, err := http.NewRequest("OPTIONS", "http://"+addr, nil)
+					Expect(err).ToNot(HaveOccurred())
+					req.Header.Set("Origin", "http://dapp.example.com")
+					req.Header.Set("Access-Control-Request-Method", "POST")
+					req.Header.Set("Access-Control-Request-Headers", "Content-Type, "+ethserver.UserHeader)
+
+					res, err := http.DefaultClient.Do(req)
+					Expect(err).ToNot(HaveOccurred())
+					res.Body.Close()
+
+					Expect(res.StatusCode).To(Equal(http.StatusOK))
+					Expect(res.Header.Get("Access-Control-Allow-Headers")).To(ContainSubstring(ethserver.UserHeader))
+				})
+
+				It("rejects malformed parameters as invalid params", func() {
+					res, err := http.Post("http://"+addr, "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"eth_getCode","params":["0x1234"],"id":1}`))
+					Expect(err).ToNot(HaveOccurred())
+					defer res.Body.Close()
+
+					body, err := ioutil.ReadAll(res.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(body).To(MatchJSON(`{"jsonrpc":"2.0","error":{"code":-32602,"message":"invalid address \"0x1234\": expected 20 bytes, got 2","data":null},"id":1}`))
+					Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+				})
+
+				It("uses the identity selected by the websocket handshake for the whole session", func() {
+					header := http.Header{}
+					header.Set(ethserver.UserHeader, "User2")
+					conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr, header)
+					Expect(err).ToNot(HaveOccurred())
+					defer conn.Close()
+
+					for i := 0; i < 2; i++ {
+						err = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_getCode","params":["0x`+contractAddress+`"],"id":1}`))
+						Expect(err).ToNot(HaveOccurred())
+						_, _, err = conn.ReadMessage()
+						Expect(err).ToNot(HaveOccurred())
+					}
+
+					Expect(fakeBackend.QueryCallCount()).To(Equal(2))
+					for i := 0; i < 2; i++ {
+						_, user, _, _, _ := fakeBackend.QueryArgsForCall(i)
+						Expect(user).To(Equal("User2"))
+					}
+				})
+			})
+		})
+
+		Context("when serving several channels", func() {
+			var (
+				ethServer *ethserver.EthServer
+				addr      string
+			)
+
+			BeforeEach(func() {
+				otherService := ethserver.NewEthService(fakeBackend, []string{"User1", "User2"}, "channel2", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+				ethServer = ethserver.NewEthServer(ethservice, otherService)
+
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				go ethServer.Serve(listener)
+				addr = "http://" + listener.Addr().String()
+			})
+
+			AfterEach(func() {
+				ethServer.Stop()
+			})
+
+			getCode := func(url string) *http.Response {
+				res, err := http.Post(url, "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"eth_getCode","params":["0x`+contractAddress+`"],"id":1}`))
+				Expect(err).ToNot(HaveOccurred())
+				res.Body.Close()
+				return res
+			}
+
+			It("routes requests to the channel in the path", func() {
+				getCode(addr + "/channel/channel2")
+				getCode(addr + "/channel/channel1")
+
+				Expect(fakeBackend.QueryCallCount()).To(Equal(2))
+				channel, _, _, _, _ := fakeBackend.QueryArgsForCall(0)
+				Expect(channel).To(Equal("channel2"))
+				channel, _, _, _, _ = fakeBackend.QueryArgsForCall(1)
+				Expect(channel).To(Equal("channel1"))
+			})
+
+			It("routes requests on the root path to the first channel", func() {
+				getCode(addr)
+
+				Expect(fakeBackend.QueryCallCount()).To(Equal(1))
+				channel, _, _, _, _ := fakeBackend.QueryArgsForCall(0)
+				Expect(channel).To(Equal("channel1"))
+			})
+
+			It("rejects channels it does not serve", func() {
+				res := getCode(addr + "/channel/channel3")
+				Expect(res.StatusCode).To(Equal(http.StatusNotFound))
+				Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+			})
+
+			It("lists the channels", func() {
+				res, err := http.Get(addr + "/channels")
+				Expect(err).ToNot(HaveOccurred())
+				defer res.Body.Close()
+
+				body, err := ioutil.ReadAll(res.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(MatchJSON(`["channel1","channel2"]`))
+			})
+
+			It("subscribes websocket connections to the blocks of the channel in the path", func() {
+				fakeBackend.SubscribeBlocksReturns(func() {}, nil)
+
+				conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(addr, "http", "ws", 1)+"/channel/channel2", nil)
+				Expect(err).ToNot(HaveOccurred())
+				defer conn.Close()
+
+				err = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscribe","params":["newHeads"],"id":1}`))
+				Expect(err).ToNot(HaveOccurred())
+				_, _, err = conn.ReadMessage()
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(fakeBackend.SubscribeBlocksCallCount()).To(Equal(1))
+				channel, _, _ := fakeBackend.SubscribeBlocksArgsForCall(0)
+				Expect(channel).To(Equal("channel2"))
+			})
+
+			It("closes the shared backend once when stopped", func() {
+				Expect(ethServer.Stop()).To(Succeed())
+				Expect(fakeBackend.CloseCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the EVM runs as a user chaincode", func() {
+			BeforeEach(func() {
+				ethservice = ethserver.NewEthService(fakeBackend, []string{"User1", "User2"}, "channel1", ethserver.ParseChaincode("evmcc:1.0"))
+			})
+
+			It("sends the requests to the chaincode", func() {
+				fakeBackend.ExecuteTxReturns(nil, sentTxID, make(chan ethserver.TxStatus), nil)
+
+				var code string
+				Expect(ethservice.GetCode(&http.Request{}, mustAddress(contractAddress), &code)).To(Succeed())
+				var result types.Bytes
+				Expect(ethservice.Call(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &result)).To(Succeed())
+				var hash types.Hash
+				Expect(ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &hash)).To(Succeed())
+
+				Expect(fakeBackend.QueryCallCount()).To(Equal(2))
+				for i := 0; i < 2; i++ {
+					_, _, chaincodeID, _, _ := fakeBackend.QueryArgsForCall(i)
+					Expect(chaincodeID).To(Equal("evmcc"))
+				}
+				_, _, chaincodeID, _, _ := fakeBackend.ExecuteTxArgsForCall(0)
+				Expect(chaincodeID).To(Equal("evmcc"))
+			})
+
+			It("checks that the chaincode is instantiated on the channel", func() {
+				fakeBackend.QueryReturns(chaincodeData("evmcc", "1.0"), nil)
+
+				Expect(ethservice.CheckChaincode()).To(Succeed())
+
+				Expect(fakeBackend.QueryCallCount()).To(Equal(1))
+				channel, user, chaincodeID, fcn, args := fakeBackend.QueryArgsForCall(0)
+				Expect(channel).To(Equal("channel1"))
+				Expect(user).To(Equal("User1"))
+				Expect(chaincodeID).To(Equal("lscc"))
+				Expect(fcn).To(Equal("getccdata"))
+				Expect(args).To(Equal([][]byte{[]byte("channel1"), []byte("evmcc")}))
+			})
+
+			It("reports a chaincode that is not instantiated", func() {
+				fakeBackend.QueryReturns(nil, errors.New("could not find chaincode with name 'evmcc'"))
+
+				err := ethservice.CheckChaincode()
+				Expect(err).To(MatchError("chaincode evmcc is not instantiated on channel channel1: could not find chaincode with name 'evmcc'"))
+			})
+
+			It("reports a chaincode instantiated at another version", func() {
+				fakeBackend.QueryReturns(chaincodeData("evmcc", "0.9"), nil)
+
+				err := ethservice.CheckChaincode()
+				Expect(err).To(MatchError("chaincode evmcc is instantiated on channel channel1 at version 0.9 instead of 1.0"))
+			})
+
+			It("accepts any version when none is configured", func() {
+				ethservice = ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.ParseChaincode("evmcc"))
+				fakeBackend.QueryReturns(chaincodeData("evmcc", "0.9"), nil)
+
+				Expect(ethservice.CheckChaincode()).To(Succeed())
+			})
+
+			It("does not check the evmscc", func() {
+				ethservice = ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.ParseChaincode(ethserver.DefaultChaincode))
+
+				Expect(ethservice.CheckChaincode()).To(Succeed())
+				Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("SendTransaction", func() {
+			It("executes a transaction and returns the transaction id", func() {
+				fakeBackend.ExecuteTxReturns(nil, sentTxID, make(chan ethserver.TxStatus), nil)
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress), Data: types.Bytes{0x56, 0x78}}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal(*mustHash(sentTxID)))
+
+				channel, user, chaincodeID, fcn, args := fakeBackend.ExecuteTxArgsForCall(0)
+				Expect(channel).To(Equal("channel1"))
+				Expect(user).To(Equal("User1"))
+				Expect(chaincodeID).To(Equal("evmscc"))
+				Expect(fcn).To(Equal(contractAddress))
+				Expect(args).To(Equal([][]byte{[]byte("5678")}))
+			})
+
+			It("invokes the zero address to deploy a contract", func() {
+				fakeBackend.ExecuteTxReturns(nil, sentTxID, make(chan ethserver.TxStatus), nil)
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{Data: types.Bytes{0x56, 0x78}}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				_, _, _, fcn, _ := fakeBackend.ExecuteTxArgsForCall(0)
+				Expect(fcn).To(Equal(zeroAddress))
+			})
+
+			It("returns an error when the transaction fails", func() {
+				fakeBackend.ExecuteTxReturns(nil, "", nil, errors.New("boom!"))
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{Data: types.Bytes{0x56, 0x78}}, &reply)
+				Expect(err).To(MatchError("boom!"))
+			})
+
+			It("accepts values and gas prices beyond 64 bits", func() {
+				var params ethserver.Params
+				err := json.Unmarshal([]byte(`{"to":"0x`+contractAddress+`","gas":"0x5208","gasPrice":"0x10000000000000000","value":"0x3635c9adc5dea00000"}`), &params)
+				Expect(err).ToNot(HaveOccurred())
+
+				thousandEther, _ := new(big.Int).SetString("1000000000000000000000", 10)
+				Expect(params.Value.ToInt()).To(Equal(thousandEther))
+				Expect(params.GasPrice.ToInt()).To(Equal(new(big.Int).Lsh(big.NewInt(1), 64)))
+				Expect(params.Gas.ToInt()).To(Equal(big.NewInt(21000)))
+			})
+		})
+
+		Context("when the endorsement fails", func() {
+			var (
+				// The ABI encoding of Error("denied"), as returned by a
+				// reverted execution
+				revertData = "08c379a0" +
+					"0000000000000000000000000000000000000000000000000000000000000020" +
+					"0000000000000000000000000000000000000000000000000000000000000006" +
+					"64656e6965640000000000000000000000000000000000000000000000000000"
+
+				revertErr      error
+				endorsementErr error
+			)
+
+			BeforeEach(func() {
+				payload, err := hex.DecodeString(revertData)
+				Expect(err).ToNot(HaveOccurred())
+
+				revertErr = status.NewFromProposalResponse(&sdkpeer.ProposalResponse{
+					Response: &sdkpeer.Response{Status: 500, Message: "failed to execute transaction: call reverted", Payload: payload},
+				}, "peer0.org1.example.com")
+				endorsementErr = status.NewFromProposalResponse(&sdkpeer.ProposalResponse{
+					Response: &sdkpeer.Response{Status: 500, Message: "access denied"},
+				}, "peer0.org1.example.com")
+			})
+
+			It("reports a reverted call with the data of the revert", func() {
+				fakeBackend.QueryReturns(nil, revertErr)
+
+				var reply types.Bytes
+				err := ethservice.Call(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(&json2.Error{
+					Code:    ethserver.ErrExecutionReverted,
+					Message: "execution reverted",
+					Data:    "0x" + revertData,
+				}))
+			})
+
+			It("reports a reverted transaction with the data of the revert", func() {
+				fakeBackend.ExecuteTxReturns(nil, "", nil, revertErr)
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(&json2.Error{
+					Code:    ethserver.ErrExecutionReverted,
+					Message: "execution reverted",
+					Data:    "0x" + revertData,
+				}))
+			})
+
+			It("reports a revert without data", func() {
+				fakeBackend.QueryReturns(nil, status.NewFromProposalResponse(&sdkpeer.ProposalResponse{
+					Response: &sdkpeer.Response{Status: 500, Message: "execution reverted"},
+				}, "peer0.org1.example.com"))
+
+				var reply types.Bytes
+				err := ethservice.Call(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(&json2.Error{Code: ethserver.ErrExecutionReverted, Message: "execution reverted"}))
+			})
+
+			It("reports other endorsement failures", func() {
+				fakeBackend.ExecuteTxReturns(nil, "", nil, endorsementErr)
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(&json2.Error{
+					Code:    ethserver.ErrEndorsementFailed,
+					Message: "endorsement failed: access denied",
+				}))
+			})
+
+			It("reports endorsements rejected by the sdk", func() {
+				fakeBackend.ExecuteTxReturns(nil, "", nil, status.New(status.EndorserClientStatus, status.EndorsementMismatch.ToInt32(), "endorsements do not match", nil))
+
+				var reply types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(&json2.Error{
+					Code:    ethserver.ErrEndorsementFailed,
+					Message: "endorsement failed: endorsements do not match",
+				}))
+			})
+
+			It("returns connection failures and other errors unchanged", func() {
+				connErr := status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection failed", nil)
+				fakeBackend.QueryReturns(nil, connErr)
+
+				var reply types.Bytes
+				err := ethservice.Call(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(connErr))
+
+				ordererErr := status.New(status.OrdererServerStatus, int32(common.Status_SERVICE_UNAVAILABLE), "orderer unavailable", nil)
+				fakeBackend.QueryReturns(nil, ordererErr)
+				err = ethservice.Call(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress)}, &reply)
+				Expect(err).To(Equal(ordererErr))
+			})
+
+			It("serves the data of a revert in the error", func() {
+				fakeBackend.QueryReturns(nil, revertErr)
+
+				ethServer := ethserver.NewEthServer(ethservice)
+				listener, err := net.Listen("tcp", "127.0.0.1:0")
+				Expect(err).ToNot(HaveOccurred())
+				go ethServer.Serve(listener)
+				defer ethServer.Stop()
+
+				res, err := http.Post("http://"+listener.Addr().String(), "application/json", strings.NewReader(`{"jsonrpc":"2.0","method":"eth_call","params":[{"to":"0x`+contractAddress+`"}],"id":1}`))
+				Expect(err).ToNot(HaveOccurred())
+				defer res.Body.Close()
+
+				body, err := ioutil.ReadAll(res.Body)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(MatchJSON(`{"jsonrpc":"2.0","error":{"code":3,"message":"execution reverted","data":"0x` + revertData + `"},"id":1}`))
+			})
+		})
+
+		Context("when tracking the commit of a transaction", func() {
+			var (
+				statuses chan ethserver.TxStatus
+				block    *common.Block
+			)
+
+			BeforeEach(func() {
+				statuses = make(chan ethserver.TxStatus, 1)
+				fakeBackend.ExecuteTxReturns(nil, invokeTxID, statuses, nil)
+
+				identity, _ := newIdentity()
+				block = newBlock(1, newTransaction(invokeTxID, identity, contractAddress, "a9059cbb", nil, ""))
+				fakeBackend.GetBlockByTxIDReturns(block, nil)
+				fakeBackend.GetTransactionByIDStub = func(channel, user, txID string) (*peer.ProcessedTransaction, error) {
+					env := &common.Envelope{}
+					err := proto.Unmarshal(block.Data.Data[0], env)
+					return &peer.ProcessedTransaction{TransactionEnvelope: env}, err
+				}
+
+				var hash types.Hash
+				err := ethservice.SendTransaction(&http.Request{}, &ethserver.Params{To: mustAddress(contractAddress), Data: types.Bytes{0xa9, 0x05, 0x9c, 0xbb}}, &hash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hash.String()).To(Equal("0x" + invokeTxID))
+			})
+
+			getReceipt := func() (*ethserver.TxReceipt, error) {
+				var reply *ethserver.TxReceipt
+				err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(invokeTxID), &reply)
+				return reply, err
+			}
+
+			It("returns a null receipt until the transaction is committed", func() {
+				reply, err := getReceipt()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(BeNil())
+				Expect(fakeBackend.GetTransactionByIDCallCount()).To(Equal(0))
+
+				statuses <- ethserver.TxStatus{ValidationCode: peer.TxValidationCode_VALID}
+
+				Eventually(getReceipt).ShouldNot(BeNil())
+				reply, err = getReceipt()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply.TransactionHash).To(Equal(*mustHash(invokeTxID)))
+			})
+
+			It("returns a null transaction until the transaction is committed", func() {
+				getTransaction := func() *ethserver.Transaction {
+					var reply *ethserver.Transaction
+					Expect(ethservice.GetTransactionByHash(&http.Request{}, mustHash(invokeTxID), &reply)).To(Succeed())
+					return reply
+				}
+
+				Expect(getTransaction()).To(BeNil())
+				Expect(fakeBackend.GetTransactionByIDCallCount()).To(Equal(0))
+
+				statuses <- ethserver.TxStatus{ValidationCode: peer.TxValidationCode_VALID}
+				Eventually(getTransaction).ShouldNot(BeNil())
+				Expect(getTransaction().Hash).To(Equal(*mustHash(invokeTxID)))
+			})
+
+			It("returns an error when the transaction failed to be committed", func() {
+				fakeBackend.GetTransactionByIDStub = nil
+				fakeBackend.GetTransactionByIDReturns(nil, errors.New("not found"))
+
+				statuses <- ethserver.TxStatus{Err: errors.New("ordering failed")}
+
+				Eventually(func() error {
+					_, err := getReceipt()
+					return err
+				}).Should(MatchError("transaction 0x" + invokeTxID + " was not committed: ordering failed"))
+			})
+		})
+
+		Context("SendRawTransaction", func() {
+			var (
+				// The example of EIP-155, signed on chain 1 with the key
+				// 0x4646...46 of the address 0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f
+				rawTx        = "0xf86c098504a817c800825208943535353535353535353535353535353535353535880de0b6b3a76400008025a028ef61340bd939bc2195fe537567866003e1a15d3c71ff63e1590620aa636276a067cbe9d8997f761aecb703304b3800ccf555c9f3dc64214b297fb1966a3b6d83"
+				signer       = "0x9d8A62f656a8d1615C1294fd71e9CFb3E4855A4F"
+				signerKey, _ = btcec.PrivKeyFromBytes(btcec.S256(), bytes.Repeat([]byte{0x46}, 32))
+
+				txHash   types.Hash
+				statuses chan ethserver.TxStatus
+			)
+
+			BeforeEach(func() {
+				raw, err := hex.DecodeString(rawTx[2:])
+				Expect(err).ToNot(HaveOccurred())
+				h := sha3.NewLegacyKeccak256()
+				h.Write(raw)
+				txHash = types.BytesToHash(h.Sum(nil))
+
+				statuses = make(chan ethserver.TxStatus, 1)
+				fakeBackend.ExecuteTxReturns(nil, rawTxID, statuses, nil)
+			})
+
+			Context("when the signer is registered", func() {
+				BeforeEach(func() {
+					Expect(ethservice.RegisterAccount(signer, "User2")).To(Succeed())
+				})
+
+				It("submits the transaction with the identity of the signer and returns its hash", func() {
+					var reply types.Hash
+					err := ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(reply).To(Equal(txHash))
+
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(1))
+					channel, user, chaincodeID, fcn, args := fakeBackend.ExecuteTxArgsForCall(0)
+					Expect(channel).To(Equal("channel1"))
+					Expect(user).To(Equal("User2"))
+					Expect(chaincodeID).To(Equal("evmscc"))
+					Expect(fcn).To(Equal(strings.Repeat("35", 20)))
+					Expect(args).To(Equal([][]byte{[]byte(""), []byte(rawTx[2:])}))
+				})
+
+				It("gets the receipt of the transaction by its hash", func() {
+					identity, _ := newIdentity()
+					block := newBlock(1, newSignedTransaction(rawTxID, identity, strings.Repeat("35", 20), "", rawTx[2:], ""))
+					fakeBackend.GetBlockByTxIDReturns(block, nil)
+					fakeBackend.GetTransactionByIDStub = func(channel, user, txID string) (*peer.ProcessedTransaction, error) {
+						env := &common.Envelope{}
+						err := proto.Unmarshal(block.Data.Data[0], env)
+						return &peer.ProcessedTransaction{TransactionEnvelope: env}, err
+					}
+
+					var hash types.Hash
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &hash)).To(Succeed())
+
+					getReceipt := func() (*ethserver.TxReceipt, error) {
+						var reply *ethserver.TxReceipt
+						err := ethservice.GetTransactionReceipt(&http.Request{}, &hash, &reply)
+						return reply, err
+					}
+
+					reply, err := getReceipt()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(reply).To(BeNil())
+
+					statuses <- ethserver.TxStatus{ValidationCode: peer.TxValidationCode_VALID}
+
+					Eventually(getReceipt).ShouldNot(BeNil())
+					reply, err = getReceipt()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(reply.TransactionHash).To(Equal(txHash))
+
+					_, _, txID := fakeBackend.GetTransactionByIDArgsForCall(0)
+					Expect(txID).To(Equal(rawTxID))
+
+					var tx *ethserver.Transaction
+					err = ethservice.GetTransactionByHash(&http.Request{}, &hash, &tx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tx.Hash).To(Equal(txHash))
+				})
+
+				It("rejects a transaction that was already submitted", func() {
+					var reply types.Hash
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)).To(Succeed())
+
+					err := ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+					Expect(err).To(MatchError("already known: transaction " + txHash.String() + " was already submitted"))
+					Expect(err.(*json2.Error).Code).To(Equal(ethserver.ErrServer))
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(1))
+				})
+
+				It("submits a transaction again when it could not be submitted", func() {
+					fakeBackend.ExecuteTxReturnsOnCall(0, nil, "", nil, errors.New("boom!"))
+
+					var reply types.Hash
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)).To(MatchError("boom!"))
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)).To(Succeed())
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(2))
+				})
+
+				It("rejects a transaction whose nonce was already used by its signer", func() {
+					var reply types.Hash
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)).To(Succeed())
+
+					err := ethservice.SendRawTransaction(&http.Request{}, mustBytes(signTransaction(signerKey, 9, []byte{0x01})), &reply)
+					Expect(err).To(MatchError("nonce too low: nonce 9 was already used, next nonce is 10"))
+					Expect(err.(*json2.Error).Code).To(Equal(ethserver.ErrServer))
+
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(signTransaction(signerKey, 11, nil)), &reply)).To(Succeed())
+					err = ethservice.SendRawTransaction(&http.Request{}, mustBytes(signTransaction(signerKey, 10, nil)), &reply)
+					Expect(err).To(MatchError("nonce too low: nonce 10 was already used, next nonce is 12"))
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(2))
+				})
+
+				It("lets the nonce of a transaction that could not be submitted be used again", func() {
+					fakeBackend.ExecuteTxReturnsOnCall(0, nil, "", nil, errors.New("boom!"))
+
+					var reply types.Hash
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)).To(MatchError("boom!"))
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(signTransaction(signerKey, 9, []byte{0x01})), &reply)).To(Succeed())
+				})
+
+				It("accepts transactions signed for its chain ID", func() {
+					ethservice.SetChainID(1)
+
+					var reply types.Hash
+					Expect(ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)).To(Succeed())
+				})
+
+				It("rejects transactions signed for another chain ID", func() {
+					ethservice.SetChainID(5)
+
+					var reply types.Hash
+					err := ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+					Expect(err).To(MatchError("transaction signed for chain ID 1, expected chain ID 5"))
+					Expect(err.(*json2.Error).Code).To(Equal(ethserver.ErrInvalidParams))
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+				})
+			})
+
+			It("rejects transactions without replay protection when it has a chain ID", func() {
+				ethservice.SetChainID(1)
+
+				var reply types.Hash
+				err := ethservice.SendRawTransaction(&http.Request{}, mustBytes("0xc98080808080801b0101"), &reply)
+				Expect(err).To(MatchError("transaction is not replay protected, expected a transaction signed for chain ID 1"))
+				Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+			})
+
+			Context("when the transaction is on the ledger", func() {
+				var block *common.Block
+
+				BeforeEach(func() {
+					identity, _ := newIdentity()
+					logs := fmt.Sprintf(`[{"address":"%s","topics":[],"data":"0x"}]`, strings.Repeat("35", 20))
+					block = newBlock(1,
+						newTransaction(invokeTxID, identity, contractAddress, "a9059cbb", nil, ""),
+						newSignedTransaction(rawTxID, identity, strings.Repeat("35", 20), "", rawTx[2:], logs),
+					)
+
+					fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: 2}, nil)
+					fakeBackend.GetBlockByNumberStub = func(channel, user string, number uint64) (*common.Block, error) {
+						if number == 1 {
+							return block, nil
+						}
+						return newBlock(number), nil
+					}
+					fakeBackend.GetBlockByTxIDReturns(block, nil)
+					fakeBackend.GetTransactionByIDStub = func(channel, user, txID string) (*peer.ProcessedTransaction, error) {
+						env := &common.Envelope{}
+						err := proto.Unmarshal(block.Data.Data[1], env)
+						return &peer.ProcessedTransaction{TransactionEnvelope: env}, err
+					}
+				})
+
+				It("finds the transaction by the hash of the signed transaction", func() {
+					// A service that did not submit the transaction, as
+					// after a restart of the proxy
+					restarted := ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+
+					// The ledger is indexed in the background
+					var reply *ethserver.TxReceipt
+					Eventually(func() *ethserver.TxReceipt {
+						reply = nil
+						restarted.GetTransactionReceipt(&http.Request{}, &txHash, &reply)
+						return reply
+					}).ShouldNot(BeNil())
+					Expect(reply.TransactionHash).To(Equal(txHash))
+					Expect(reply.TransactionIndex).To(Equal(types.Quantity(1)))
+					Expect(reply.Logs).To(HaveLen(1))
+					Expect(reply.Logs[0].TransactionHash).To(Equal(txHash))
+
+					_, _, txID := fakeBackend.GetTransactionByIDArgsForCall(fakeBackend.GetTransactionByIDCallCount() - 1)
+					Expect(txID).To(Equal(rawTxID))
+
+					var tx *ethserver.Transaction
+					err := restarted.GetTransactionByHash(&http.Request{}, &txHash, &tx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tx.Hash).To(Equal(txHash))
+
+					// The blocks are only scanned once
+					Expect(fakeBackend.GetBlockByNumberCallCount()).To(Equal(2))
+				})
+
+				It("finds the transactions committed once the ledger is indexed", func() {
+					fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: 1}, nil)
+					restarted := ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+					Expect(restarted.RegisterAccount(signer, "User1")).To(Succeed())
+
+					getReceipt := func() *ethserver.TxReceipt {
+						var reply *ethserver.TxReceipt
+						restarted.GetTransactionReceipt(&http.Request{}, &txHash, &reply)
+						return reply
+					}
+					Consistently(getReceipt, 50*time.Millisecond).Should(BeNil())
+
+					Expect(fakeBackend.SubscribeBlocksCallCount()).To(Equal(1))
+					_, _, callback := fakeBackend.SubscribeBlocksArgsForCall(0)
+					callback(block)
+
+					Eventually(getReceipt).ShouldNot(BeNil())
+					Expect(fakeBackend.GetBlockByNumberCallCount()).To(Equal(1))
+				})
+
+				It("loads the index again when it failed to", func() {
+					fakeBackend.GetChainInfoReturnsOnCall(0, nil, errors.New("boom!"))
+					restarted := ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+					Expect(restarted.RegisterAccount(signer, "User1")).To(Succeed())
+
+					var reply types.Hash
+					err := restarted.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+					Expect(err).To(MatchError("boom!"))
+
+					err = restarted.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+					Expect(err).To(MatchError("already known: transaction " + txHash.String() + " was already submitted"))
+					Expect(fakeBackend.SubscribeBlocksCallCount()).To(Equal(2))
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+				})
+
+				It("rejects a transaction reusing the nonce of a transaction on the ledger", func() {
+					restarted := ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+					Expect(restarted.RegisterAccount(signer, "User1")).To(Succeed())
+
+					var reply types.Hash
+					err := restarted.SendRawTransaction(&http.Request{}, mustBytes(signTransaction(signerKey, 9, []byte{0x01})), &reply)
+					Expect(err).To(MatchError("nonce too low: nonce 9 was already used, next nonce is 10"))
+					Expect(restarted.SendRawTransaction(&http.Request{}, mustBytes(signTransaction(signerKey, 10, nil)), &reply)).To(Succeed())
+				})
+
+				It("rejects the transaction when it is sent again", func() {
+					restarted := ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+					Expect(restarted.RegisterAccount(signer, "User1")).To(Succeed())
+
+					var reply types.Hash
+					err := restarted.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+					Expect(err).To(MatchError("already known: transaction " + txHash.String() + " was already submitted"))
+					Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+				})
+
+				It("shows the hash of the signed transaction in blocks and logs", func() {
+					var blk ethserver.Block
+					err := ethservice.GetBlockByNumber(&http.Request{}, &ethserver.BlockNumberParams{Block: types.BlockNumber(1)}, &blk)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(blk.Transactions).To(Equal([]interface{}{*mustHash(invokeTxID), txHash}))
+
+					var logs []ethserver.Log
+					err = ethservice.GetLogs(&http.Request{}, &ethserver.FilterCriteria{}, &logs)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(logs).To(HaveLen(1))
+					Expect(logs[0].TransactionHash).To(Equal(txHash))
+				})
+			})
+
+			It("rejects transactions from signers that are not registered", func() {
+				var reply types.Hash
+				err := ethservice.SendRawTransaction(&http.Request{}, mustBytes(rawTx), &reply)
+				Expect(err).To(MatchError("account 0x9d8a62f656a8d1615c1294fd71e9cfb3e4855a4f is not registered to a fabric identity"))
+				Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+			})
+
+			It("rejects malformed transactions", func() {
+				var reply types.Hash
+				err := ethservice.SendRawTransaction(&http.Request{}, mustBytes("0xc0"), &reply)
+				Expect(err).To(MatchError("invalid raw transaction: expected 9 transaction fields, got 0"))
+				Expect(fakeBackend.ExecuteTxCallCount()).To(Equal(0))
+			})
+
+			It("only registers accounts to identities it holds", func() {
+				Expect(ethservice.RegisterAccount(signer, "User3")).To(MatchError("unknown user User3"))
+				Expect(ethservice.RegisterAccount("0x1234", "User1")).To(MatchError("invalid account address 0x1234"))
+			})
+		})
+
+		Context("Transactions", func() {
+			var (
+				identity []byte
+				address  types.Address
+				block    *common.Block
+
+				validationCode peer.TxValidationCode
+			)
+
+			BeforeEach(func() {
+				identity, address = newIdentity()
+				validationCode = peer.TxValidationCode_VALID
+
+				logs := fmt.Sprintf(`[{"address":"%s","topics":["0x%s"],"data":"0x01"}]`, contractAddress, strings.ToUpper(transferTopic))
+				block = newBlock(3,
+					newTransaction(deployTxID, identity, zeroAddress, "6060", []byte(contractAddress), ""),
+					newTransaction(invokeTxID, identity, contractAddress, "a9059cbb", nil, logs),
+				)
+
+				fakeBackend.GetBlockByTxIDReturns(block, nil)
+				fakeBackend.GetTransactionByIDStub = func(channel, user, txID string) (*peer.ProcessedTransaction, error) {
+					index := map[string]int{deployTxID: 0, invokeTxID: 1}[txID]
+					env := &common.Envelope{}
+					err := proto.Unmarshal(block.Data.Data[index], env)
+					return &peer.ProcessedTransaction{TransactionEnvelope: env, ValidationCode: int32(validationCode)}, err
+				}
+			})
+
+			It("returns the receipt of a contract deployment", func() {
+				var reply *ethserver.TxReceipt
+				err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(deployTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reply).To(Equal(&ethserver.TxReceipt{
+					TransactionHash:  *mustHash(deployTxID),
+					TransactionIndex: 0,
+					BlockHash:        types.BytesToHash(block.Header.Hash()),
+					BlockNumber:      3,
+					From:             address,
+					ContractAddress:  mustAddress(contractAddress),
+					Logs:             []ethserver.Log{},
+					LogsBloom:        make(types.Bytes, 256),
+					Status:           1,
+					ValidationCode:   "VALID",
+				}))
+
+				channel, user, txID := fakeBackend.GetTransactionByIDArgsForCall(0)
+				Expect(channel).To(Equal("channel1"))
+				Expect(user).To(Equal("User1"))
+				Expect(txID).To(Equal(deployTxID))
+			})
+
+			It("returns a failed receipt for invalidated transactions", func() {
+				validationCode = peer.TxValidationCode_MVCC_READ_CONFLICT
+
+				var reply *ethserver.TxReceipt
+				err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(deployTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reply.Status).To(Equal(types.Quantity(0)))
+				Expect(reply.ValidationCode).To(Equal("MVCC_READ_CONFLICT"))
+				Expect(reply.ContractAddress).To(BeNil())
+			})
+
+			It("returns the logs of the transaction in its receipt", func() {
+				var reply *ethserver.TxReceipt
+				err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(invokeTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reply.ContractAddress).To(BeNil())
+				Expect(reply.To).To(Equal(mustAddress(contractAddress)))
+				Expect(reply.TransactionIndex).To(Equal(types.Quantity(1)))
+				Expect(reply.LogsBloom).To(HaveLen(256))
+				Expect(reply.LogsBloom).ToNot(Equal(make(types.Bytes, 256)))
+				Expect(reply.Logs).To(Equal([]ethserver.Log{{
+					Address:          *mustAddress(contractAddress),
+					Topics:           []types.Hash{*mustHash(transferTopic)},
+					Data:             types.Bytes{0x01},
+					BlockNumber:      3,
+					BlockHash:        types.BytesToHash(block.Header.Hash()),
+					TransactionHash:  *mustHash(invokeTxID),
+					TransactionIndex: 1,
+					LogIndex:         0,
+				}}))
+			})
+
+			It("returns the transaction", func() {
+				var reply *ethserver.Transaction
+				err := ethservice.GetTransactionByHash(&http.Request{}, mustHash(invokeTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+
+				Expect(reply).To(Equal(&ethserver.Transaction{
+					BlockHash:        types.BytesToHash(block.Header.Hash()),
+					BlockNumber:      3,
+					From:             address,
+					Hash:             *mustHash(invokeTxID),
+					Input:            types.Bytes{0xa9, 0x05, 0x9c, 0xbb},
+					To:               mustAddress(contractAddress),
+					TransactionIndex: 1,
+				}))
+			})
+
+			It("returns a null to for contract deployments", func() {
+				var reply *ethserver.Transaction
+				err := ethservice.GetTransactionByHash(&http.Request{}, mustHash(deployTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply.To).To(BeNil())
+				Expect(reply.Input).To(Equal(types.Bytes{0x60, 0x60}))
+			})
+
+			It("accepts hashes with or without the 0x prefix, in any case", func() {
+				for _, hash := range []string{invokeTxID, "0x" + invokeTxID, "0X" + strings.ToUpper(invokeTxID)} {
+					var receipt *ethserver.TxReceipt
+					err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(hash), &receipt)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(receipt.TransactionHash.String()).To(Equal("0x" + invokeTxID))
+
+					var tx *ethserver.Transaction
+					err = ethservice.GetTransactionByHash(&http.Request{}, mustHash(hash), &tx)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tx.Hash.String()).To(Equal("0x" + invokeTxID))
+				}
+
+				for i := 0; i < fakeBackend.GetTransactionByIDCallCount(); i++ {
+					_, _, txID := fakeBackend.GetTransactionByIDArgsForCall(i)
+					Expect(txID).To(Equal(invokeTxID))
+				}
+			})
+
+			It("returns a null receipt for a transaction that is not on the ledger", func() {
+				fakeBackend.GetTransactionByIDStub = nil
+				fakeBackend.GetTransactionByIDReturns(nil, ethserver.ErrTxNotFound)
+
+				reply := &ethserver.TxReceipt{}
+				err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(otherTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(BeNil())
+			})
+
+			It("returns an error when the ledger cannot be queried for the receipt", func() {
+				fakeBackend.GetTransactionByIDStub = nil
+				fakeBackend.GetTransactionByIDReturns(nil, errors.New("connection refused"))
+
+				var reply *ethserver.TxReceipt
+				err := ethservice.GetTransactionReceipt(&http.Request{}, mustHash(otherTxID), &reply)
+				Expect(err).To(MatchError("connection refused"))
+			})
+
+			It("returns a null transaction when the transaction is not on the ledger", func() {
+				fakeBackend.GetTransactionByIDStub = nil
+				fakeBackend.GetTransactionByIDReturns(nil, ethserver.ErrTxNotFound)
+
+				reply := &ethserver.Transaction{}
+				err := ethservice.GetTransactionByHash(&http.Request{}, mustHash(otherTxID), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(BeNil())
+			})
+
+			It("returns an error when the ledger cannot be queried for the transaction", func() {
+				fakeBackend.GetTransactionByIDStub = nil
+				fakeBackend.GetTransactionByIDReturns(nil, errors.New("connection refused"))
+
+				var reply *ethserver.Transaction
+				err := ethservice.GetTransactionByHash(&http.Request{}, mustHash(otherTxID), &reply)
+				Expect(err).To(MatchError("connection refused"))
+			})
+		})
+
+		Context("Accounts", func() {
+			It("returns the addresses of all the identities", func() {
+				var reply []types.Address
+				err := ethservice.Accounts(&http.Request{}, dataParam(""), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal([]types.Address{addresses["User1"], addresses["User2"]}))
+
+				Expect(fakeBackend.QueryCallCount()).To(Equal(0))
+			})
+
+			It("derives the addresses the evmscc gives the identities", func() {
+				identity, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: []byte(user1Cert)})
+				Expect(err).ToNot(HaveOccurred())
+				fakeBackend.IdentityStub = nil
+				fakeBackend.IdentityReturns(identity, nil)
+				ethservice = ethserver.NewEthService(fakeBackend, []string{"User1"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+
+				var reply []types.Address
+				err = ethservice.Accounts(&http.Request{}, dataParam(""), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal([]types.Address{*mustAddress("0xae1084a9993db2003a20b1aac22031e7b33772cf")}))
+			})
+
+			It("returns an error when an identity cannot be loaded", func() {
+				ethservice = ethserver.NewEthService(fakeBackend, []string{"User1", "User3"}, "channel1", ethserver.Chaincode{Name: ethserver.DefaultChaincode})
+
+				var reply []types.Address
+				err := ethservice.Accounts(&http.Request{}, dataParam(""), &reply)
+				Expect(err).To(MatchError("user not found"))
+			})
+		})
+
+		Context("Blocks", func() {
+			var (
+				identity []byte
+				blocks   []*common.Block
+			)
+
+			BeforeEach(func() {
+				identity, _ = newIdentity()
+
+				blocks = []*common.Block{
+					newBlock(0),
+					newBlock(1, newTransaction(blockTxIDs[1], identity, contractAddress, "01", nil, fmt.Sprintf(`[{"address":"%s","topics":["0x%s"],"data":"0x"}]`, contractAddress, transferTopic))),
+					newBlock(2, newTransaction(blockTxIDs[2], identity, otherContractAddress, "02", nil, fmt.Sprintf(`[{"address":"%s","topics":["0x%s"],"data":"0x"}]`, otherContractAddress, transferTopic))),
+				}
+
+				fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: uint64(len(blocks))}, nil)
+				fakeBackend.GetBlockByNumberStub = func(channel, user string, number uint64) (*common.Block, error) {
+					return blocks[number], nil
+				}
+			})
+
+			It("returns the latest block number", func() {
+				var reply types.Quantity
+				err := ethservice.BlockNumber(&http.Request{}, dataParam(""), &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(Equal(types.Quantity(2)))
+			})
+
+			It("returns the block by number", func() {
+				var reply ethserver.Block
+				err := ethservice.GetBlockByNumber(&http.Request{}, &ethserver.BlockNumberParams{Block: types.BlockNumber(1)}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply.Number).To(Equal(types.Quantity(1)))
+				Expect(reply.Hash).To(Equal(types.BytesToHash(blocks[1].Header.Hash())))
+				Expect(reply.Transactions).To(Equal([]interface{}{*mustHash(blockTxIDs[1])}))
+
+				_, _, number := fakeBackend.GetBlockByNumberArgsForCall(0)
+				Expect(number).To(Equal(uint64(1)))
+			})
+
+			It("resolves the latest tag", func() {
+				var reply ethserver.Block
+				err := ethservice.GetBlockByNumber(&http.Request{}, &ethserver.BlockNumberParams{Block: types.Tagged(types.LatestBlock), FullTransactions: true}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply.Number).To(Equal(types.Quantity(2)))
+				Expect(reply.Transactions).To(HaveLen(1))
+				Expect(reply.Transactions[0]).To(BeAssignableToTypeOf(ethserver.Transaction{}))
+			})
+
+			It("decodes the block parameters", func() {
+				var params ethserver.BlockNumberParams
+				Expect(json.Unmarshal([]byte(`["earliest",true]`), &params)).To(Succeed())
+				Expect(params).To(Equal(ethserver.BlockNumberParams{Block: types.Tagged(types.EarliestBlock), FullTransactions: true}))
+
+				Expect(json.Unmarshal([]byte(`["0xnope"]`), &params)).To(MatchError(`invalid block number "0xnope": expected a quantity or one of latest, earliest and pending`))
+				Expect(json.Unmarshal([]byte(`[]`), &params)).To(MatchError("missing block parameter"))
+
+				var hashParams ethserver.BlockHashParams
+				Expect(json.Unmarshal([]byte(`["0xabcd"]`), &hashParams)).To(MatchError(`invalid hash "0xabcd": expected 32 bytes, got 2`))
+			})
+
+			It("returns the block by hash", func() {
+				fakeBackend.GetBlockByHashReturns(blocks[2], nil)
+
+				var reply ethserver.Block
+				hash := types.BytesToHash(blocks[2].Header.Hash())
+				err := ethservice.GetBlockByHash(&http.Request{}, &ethserver.BlockHashParams{Block: hash}, &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply.Number).To(Equal(types.Quantity(2)))
+
+				_, _, requested := fakeBackend.GetBlockByHashArgsForCall(0)
+				Expect(requested).To(Equal(blocks[2].Header.Hash()))
+			})
+
+			It("returns the logs matching the criteria", func() {
+				var reply []ethserver.Log
+				criteria := &ethserver.FilterCriteria{}
+				err := json.Unmarshal([]byte(`{"fromBlock":"earliest","toBlock":"latest","address":"0x`+otherContractAddress+`"}`), criteria)
+				Expect(err).ToNot(HaveOccurred())
+
+				err = ethservice.GetLogs(&http.Request{}, criteria, &reply)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reply).To(HaveLen(1))
+				Expect(reply[0].TransactionHash).To(Equal(*mustHash(blockTxIDs[2])))
+				Expect(reply[0].BlockNumber).To(Equal(types.Quantity(2)))
+			})
+
+			It("returns the logs matching the topics of the criteria", func() {
+				getLogs := func(filter string) []ethserver.Log {
+					criteria := &ethserver.FilterCriteria{}
+					Expect(json.Unmarshal([]byte(filter), criteria)).To(Succeed())
+
+					var reply []ethserver.Log
+					Expect(ethservice.GetLogs(&http.Request{}, criteria, &reply)).To(Succeed())
+					return reply
+				}
+
+				logs := getLogs(`{"fromBlock":"0x1","toBlock":"latest","topics":["0x` + transferTopic + `"]}`)
+				Expect(logs).To(HaveLen(2))
+				Expect(logs[0].TransactionHash).To(Equal(*mustHash(blockTxIDs[1])))
+				Expect(logs[1].TransactionHash).To(Equal(*mustHash(blockTxIDs[2])))
+
+				logs = getLogs(`{"fromBlock":"0x1","toBlock":"latest","address":["0x` + contractAddress + `"],"topics":[["0x` + transferTopic + `"]]}`)
+				Expect(logs).To(HaveLen(1))
+				Expect(logs[0].Address).To(Equal(*mustAddress(contractAddress)))
+
+				Expect(getLogs(`{"fromBlock":"0x1","toBlock":"latest","topics":["0x` + strings.Repeat("00", 32) + `"]}`)).To(BeEmpty())
+				Expect(getLogs(`{"fromBlock":"0x1","toBlock":"0x1","address":"0x` + otherContractAddress + `"}`)).To(BeEmpty())
+			})
+
+			It("reports the blocks committed since the last poll of a block filter", func() {
+				fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: 1}, nil)
+
+				var id string
+				err := ethservice.NewBlockFilter(&http.Request{}, dataParam(""), &id)
+				Expect(err).ToNot(HaveOccurred())
+
+				fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: 3}, nil)
+
+				var changes []interface{}
+				err = ethservice.GetFilterChanges(&http.Request{}, dataParam(id), &changes)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(Equal([]interface{}{
+					types.BytesToHash(blocks[1].Header.Hash()),
+					types.BytesToHash(blocks[2].Header.Hash()),
+				}))
+
+				err = ethservice.GetFilterChanges(&http.Request{}, dataParam(id), &changes)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(BeEmpty())
+
+				var removed bool
+				err = ethservice.UninstallFilter(&http.Request{}, dataParam(id), &removed)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(removed).To(BeTrue())
+
+				err = ethservice.GetFilterChanges(&http.Request{}, dataParam(id), &changes)
+				Expect(err).To(MatchError("filter not found"))
+			})
+
+			It("reports the logs of a log filter matching its criteria", func() {
+				fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: 1}, nil)
+
+				criteria := &ethserver.FilterCriteria{}
+				err := json.Unmarshal([]byte(`{"address":"0x`+otherContractAddress+`","topics":["0x`+transferTopic+`"]}`), criteria)
+				Expect(err).ToNot(HaveOccurred())
+
+				var id string
+				err = ethservice.NewFilter(&http.Request{}, criteria, &id)
+				Expect(err).ToNot(HaveOccurred())
+
+				fakeBackend.GetChainInfoReturns(&common.BlockchainInfo{Height: 3}, nil)
+
+				var changes []interface{}
+				err = ethservice.GetFilterChanges(&http.Request{}, dataParam(id), &changes)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(HaveLen(1))
+				Expect(changes[0]).To(BeAssignableToTypeOf(ethserver.Log{}))
+				log := changes[0].(ethserver.Log)
+				Expect(log.Address).To(Equal(*mustAddress(otherContractAddress)))
+				Expect(log.BlockNumber).To(Equal(types.Quantity(2)))
+
+				err = ethservice.GetFilterChanges(&http.Request{}, dataParam(id), &changes)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(BeEmpty())
+			})
+
+			It("reports the logs of past blocks to a log filter starting from them", func() {
+				criteria := &ethserver.FilterCriteria{}
+				err := json.Unmarshal([]byte(`{"fromBlock":"earliest","toBlock":"0x1","topics":[["0x`+transferTopic+`"]]}`), criteria)
+				Expect(err).ToNot(HaveOccurred())
+
+				var id string
+				err = ethservice.NewFilter(&http.Request{}, criteria, &id)
+				Expect(err).ToNot(HaveOccurred())
+
+				var changes []interface{}
+				err = ethservice.GetFilterChanges(&http.Request{}, dataParam(id), &changes)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(changes).To(HaveLen(1))
+				Expect(changes[0].(ethserver.Log).Address).To(Equal(*mustAddress(contractAddress)))
+			})
+
+			Context("when subscribing over a websocket", func() {
+				var (
+					ethServer *ethserver.EthServer
+					addr      string
+					conn      *websocket.Conn
+				)
+
+				BeforeEach(func() {
+					fakeBackend.SubscribeBlocksReturns(func() {}, nil)
+
+					ethServer = ethserver.NewEthServer(ethservice)
+					listener, err := net.Listen("tcp", "127.0.0.1:0")
+					Expect(err).ToNot(HaveOccurred())
+					go ethServer.Serve(listener)
+					addr = "ws://" + listener.Addr().String()
+
+					conn, _, err = websocket.DefaultDialer.Dial(addr, nil)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				AfterEach(func() {
+					conn.Close()
+					ethServer.Stop()
+				})
+
+				type notification struct {
+					Method string `json:"method"`
+					Params struct {
+						Subscription string          `json:"subscription"`
+						Result       json.RawMessage `json:"result"`
+					} `json:"params"`
+				}
+
+				type response struct {
+					Result json.RawMessage `json:"result"`
+					Error  *json2.Error    `json:"error"`
+				}
+
+				call := func(request string) response {
+					Expect(conn.WriteMessage(websocket.TextMessage, []byte(request))).To(Succeed())
+					var res response
+					Expect(conn.ReadJSON(&res)).To(Succeed())
+					return res
+				}
+
+				subscribe := func(params string) (string, func(*common.Block)) {
+					res := call(`{"jsonrpc":"2.0","method":"eth_subscribe","params":` + params + `,"id":1}`)
+					Expect(res.Error).To(BeNil())
+
+					var id string
+					Expect(json.Unmarshal(res.Result, &id)).To(Succeed())
+					_, _, callback := fakeBackend.SubscribeBlocksArgsForCall(fakeBackend.SubscribeBlocksCallCount() - 1)
+					return id, callback
+				}
+
+				readNotification := func() notification {
+					var n notification
+					Expect(conn.ReadJSON(&n)).To(Succeed())
+					Expect(n.Method).To(Equal("eth_subscription"))
+					return n
+				}
+
+				It("notifies the headers of new blocks", func() {
+					id, callback := subscribe(`["newHeads"]`)
+					callback(blocks[1])
+
+					n := readNotification()
+					Expect(n.Params.Subscription).To(Equal(id))
+					var header ethserver.Block
+					Expect(json.Unmarshal(n.Params.Result, &header)).To(Succeed())
+					Expect(header.Number).To(Equal(types.Quantity(1)))
+					Expect(header.Hash).To(Equal(types.BytesToHash(blocks[1].Header.Hash())))
+				})
+
+				It("notifies the logs of new blocks matching the criteria", func() {
+					id, callback := subscribe(`["logs",{"address":"0x` + otherContractAddress + `"}]`)
+					callback(blocks[1])
+					callback(blocks[2])
+
+					n := readNotification()
+					Expect(n.Params.Subscription).To(Equal(id))
+					var log ethserver.Log
+					Expect(json.Unmarshal(n.Params.Result, &log)).To(Succeed())
+					Expect(log.Address).To(Equal(*mustAddress(otherContractAddress)))
+					Expect(log.TransactionHash).To(Equal(*mustHash(blockTxIDs[2])))
+				})
+
+				It("does not hold up the other subscribers when a client stops reading", func() {
+					stalled, _, err := websocket.DefaultDialer.Dial(addr, nil)
+					Expect(err).ToNot(HaveOccurred())
+					defer stalled.Close()
+
+					Expect(stalled.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"eth_subscribe","params":["newHeads"],"id":1}`))).To(Succeed())
+					Eventually(fakeBackend.SubscribeBlocksCallCount).Should(Equal(1))
+					_, _, stalledCallback := fakeBackend.SubscribeBlocksArgsForCall(0)
+
+					id, callback := subscribe(`["newHeads"]`)
+
+					// Far more notifications than the socket buffers hold
+					delivered := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						defer close(delivered)
+						for i := 0; i < 20000; i++ {
+							stalledCallback(blocks[1])
+						}
+						callback(blocks[2])
+					}()
+					Eventually(delivered, 10*time.Second).Should(BeClosed())
+
+					n := readNotification()
+					Expect(n.Params.Subscription).To(Equal(id))
+
+					// The client that fell behind is disconnected
+					stalled.SetReadDeadline(time.Now().Add(10 * time.Second))
+					for err == nil {
+						_, _, err = stalled.ReadMessage()
+					}
+					Expect(websocket.IsUnexpectedCloseError(err)).To(BeTrue())
+				})
+
+				It("cancels a subscription when unsubscribed", func() {
+					cancelled := make(chan struct{})
+					fakeBackend.SubscribeBlocksReturns(func() { close(cancelled) }, nil)
+					id, _ := subscribe(`["newHeads"]`)
+
+					res := call(`{"jsonrpc":"2.0","method":"eth_unsubscribe","params":["` + id + `"],"id":2}`)
+					Expect(res.Result).To(MatchJSON("true"))
+					Expect(cancelled).To(BeClosed())
+
+					res = call(`{"jsonrpc":"2.0","method":"eth_unsubscribe","params":["` + id + `"],"id":3}`)
+					Expect(res.Result).To(MatchJSON("false"))
+				})
+
+				It("rejects malformed parameters as invalid params", func() {
+					for _, request := range []string{
+						`{"jsonrpc":"2.0","method":"eth_subscribe","params":[],"id":1}`,
+						`{"jsonrpc":"2.0","method":"eth_subscribe","params":[1],"id":1}`,
+						`{"jsonrpc":"2.0","method":"eth_subscribe","params":"newHeads","id":1}`,
+						`{"jsonrpc":"2.0","method":"eth_subscribe","params":["pendingTransactions"],"id":1}`,
+						`{"jsonrpc":"2.0","method":"eth_subscribe","params":["logs",{"address":"0x1234"}],"id":1}`,
+						`{"jsonrpc":"2.0","method":"eth_unsubscribe","params":[{}],"id":1}`,
+						`{"jsonrpc":"2.0","method":"eth_unsubscribe","id":1}`,
+					} {
+						res := call(request)
+						Expect(res.Error).ToNot(BeNil(), request)
+						Expect(res.Error.Code).To(Equal(json2.E_BAD_PARAMS), request)
+					}
+					Expect(fakeBackend.SubscribeBlocksCallCount()).To(Equal(0))
+				})
 			})
 		})
 	})
 })
+
+// Fabric transaction IDs are the hex encoding of a sha256 hash
+var (
+	deployTxID = strings.Repeat("d0", 32)
+	invokeTxID = strings.Repeat("1f", 32)
+	rawTxID    = strings.Repeat("2a", 32)
+	otherTxID  = strings.Repeat("0f", 32)
+	sentTxID   = strings.Repeat("5e", 32)
+	blockTxIDs = []string{"", strings.Repeat("b1", 32), strings.Repeat("b2", 32)}
+)
+
+var (
+	zeroAddress          = hex.EncodeToString(make([]byte, 20))
+	contractAddress      = "82373458" + strings.Repeat("00", 16)
+	otherContractAddress = "12345678" + strings.Repeat("00", 16)
+	transferTopic        = "ddf252ad" + strings.Repeat("00", 28)
+)
+
+func mustAddress(s string) *types.Address {
+	address, err := types.HexToAddress(s)
+	Expect(err).ToNot(HaveOccurred())
+	return &address
+}
+
+func addressPtr(address types.Address) *types.Address {
+	return &address
+}
+
+func mustHash(s string) *types.Hash {
+	hash, err := types.HexToHash(s)
+	Expect(err).ToNot(HaveOccurred())
+	return &hash
+}
+
+func mustBytes(s string) *types.Bytes {
+	b, err := types.HexToBytes(s)
+	Expect(err).ToNot(HaveOccurred())
+	return &b
+}
+
+func dataParam(s string) *ethserver.DataParam {
+	p := ethserver.DataParam(s)
+	return &p
+}
+
+// newIdentity returns a serialized msp identity with a freshly generated
+// certificate and the ethereum address derived from its public key
+func newIdentity() ([]byte, types.Address) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+
+	identity, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   "Org1MSP",
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	pubKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	h := sha3.NewLegacyKeccak256()
+	h.Write(pubKey)
+	return identity, types.BytesToAddress(h.Sum(nil))
+}
+
+// user1Cert is a certificate whose address, as derived by the evmscc, is
+// 0xae1084a9993db2003a20b1aac22031e7b33772cf
+const user1Cert = `-----BEGIN CERTIFICATE-----
+MIIBmTCCAT+gAwIBAgIUTKDorw1DGCKaGXQlQX+2uAvEriYwCgYIKoZIzj0EAwIw
+ITEfMB0GA1UEAwwWVXNlcjFAb3JnMS5leGFtcGxlLmNvbTAgFw0yNjEwMTYwNjEw
+MDBaGA8yMTI2MDkyMjA2MTAwMFowITEfMB0GA1UEAwwWVXNlcjFAb3JnMS5leGFt
+cGxlLmNvbTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABHzx3eJ4JEmO8e8S8F9x
+hLsRquX+iCI2uPF+rZM06BD+W2BeIO9niCn20Ko2IGBYBB1zbf0OQAeHNX6zEWch
+LEyjUzBRMB0GA1UdDgQWBBSeUm7GofWg3Nt6wfBMNZoq0XjCADAfBgNVHSMEGDAW
+gBSeUm7GofWg3Nt6wfBMNZoq0XjCADAPBgNVHRMBAf8EBTADAQH/MAoGCCqGSM49
+BAMCA0gAMEUCIQCSRbbizeyhVR1khbc6Vh+UzEf7I8bNSW+ycBX5uGI8ugIgEraR
+8FMYPVTJp2m7VJu2pl3URVYVz4j7oQ9VGjCCT1Q=
+-----END CERTIFICATE-----
+`
+
+// newTransaction builds the envelope of an evmscc invocation of the callee
+// with the input data, endorsed with the response payload and logs
+func newTransaction(txID string, creator []byte, callee, input string, response []byte, logs string) []byte {
+	return newInvocation(txID, creator, [][]byte{[]byte(callee), []byte(input)}, response, logs)
+}
+
+// newSignedTransaction builds the envelope of an evmscc invocation submitted
+// for the hex encoded signed ethereum transaction
+func newSignedTransaction(txID string, creator []byte, callee, input, raw, logs string) []byte {
+	return newInvocation(txID, creator, [][]byte{[]byte(callee), []byte(input), []byte(raw)}, nil, logs)
+}
+
+func newInvocation(txID string, creator []byte, args [][]byte, response []byte, logs string) []byte {
+	invokeSpec := &peer.ChaincodeInvocationSpec{
+		ChaincodeSpec: &peer.ChaincodeSpec{
+			ChaincodeId: &peer.ChaincodeID{Name: "evmscc"},
+			Input:       &peer.ChaincodeInput{Args: args},
+		},
+	}
+
+	action := &peer.ChaincodeAction{Response: &peer.Response{Status: 200, Payload: response}}
+	if logs != "" {
+		action.Events = mustMarshal(&peer.ChaincodeEvent{ChaincodeId: "evmscc", TxId: txID, Payload: []byte(logs)})
+	}
+
+	actionPayload := &peer.ChaincodeActionPayload{
+		ChaincodeProposalPayload: mustMarshal(&peer.ChaincodeProposalPayload{Input: mustMarshal(invokeSpec)}),
+		Action: &peer.ChaincodeEndorsedAction{
+			ProposalResponsePayload: mustMarshal(&peer.ProposalResponsePayload{Extension: mustMarshal(action)}),
+		},
+	}
+
+	payload := &common.Payload{
+		Header: &common.Header{
+			ChannelHeader: mustMarshal(&common.ChannelHeader{
+				Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+				ChannelId: "channel1",
+				TxId:      txID,
+				Timestamp: &timestamp.Timestamp{Seconds: 1500000000},
+			}),
+			SignatureHeader: mustMarshal(&common.SignatureHeader{Creator: creator}),
+		},
+		Data: mustMarshal(&peer.Transaction{
+			Actions: []*peer.TransactionAction{{Payload: mustMarshal(actionPayload)}},
+		}),
+	}
+
+	return mustMarshal(&common.Envelope{Payload: mustMarshal(payload)})
+}
+
+// signTransaction returns the hex encoding of a transaction without replay
+// protection to the zero address with the nonce and input data, signed with
+// the key
+func signTransaction(key *btcec.PrivateKey, nonce uint64, data []byte) string {
+	rlpString := func(b []byte) []byte {
+		if len(b) == 1 && b[0] < 0x80 {
+			return b
+		}
+		Expect(len(b)).To(BeNumerically("<=", 55))
+		return append([]byte{0x80 + byte(len(b))}, b...)
+	}
+	rlpList := func(items ...[]byte) []byte {
+		var payload []byte
+		for _, item := range items {
+			payload = append(payload, rlpString(item)...)
+		}
+		Expect(len(payload)).To(BeNumerically("<", 256))
+		return append([]byte{0xf8, byte(len(payload))}, payload...)
+	}
+
+	fields := [][]byte{new(big.Int).SetUint64(nonce).Bytes(), nil, {0x52, 0x08}, make([]byte, 20), nil, data}
+	signed := rlpList(fields...)
+	if signed[1] < 56 {
+		signed = append([]byte{0xc0 + signed[1]}, signed[2:]...)
+	}
+	h := sha3.NewLegacyKeccak256()
+	h.Write(signed)
+
+	sig, err := btcec.SignCompact(btcec.S256(), key, h.Sum(nil), false)
+	Expect(err).ToNot(HaveOccurred())
+	return "0x" + hex.EncodeToString(rlpList(append(fields, sig[:1], new(big.Int).SetBytes(sig[1:33]).Bytes(), new(big.Int).SetBytes(sig[33:]).Bytes())...))
+}
+
+func newBlock(number uint64, envelopes ...[]byte) *common.Block {
+	return &common.Block{
+		Header: &common.BlockHeader{
+			Number:       number,
+			PreviousHash: []byte{byte(number)},
+		},
+		Data: &common.BlockData{Data: envelopes},
+	}
+}
+
+// chaincodeData encodes the name, version and escc fields of the
+// ccprovider.ChaincodeData returned by the lscc
+func chaincodeData(name, version string) []byte {
+	buf := proto.NewBuffer(nil)
+	for i, field := range []string{name, version, "escc"} {
+		buf.EncodeVarint(uint64(i+1)<<3 | proto.WireBytes)
+		buf.EncodeStringBytes(field)
+	}
+	return buf.Bytes()
+}
+
+func mustMarshal(msg proto.Message) []byte {
+	b, err := proto.Marshal(msg)
+	Expect(err).ToNot(HaveOccurred())
+	return b
+}
@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTxRetention is how long the outcome of a transaction is
+	// remembered once it is known
+	DefaultTxRetention = 5 * time.Minute
+	// DefaultTxTimeout is how long the outcome of the commit of a
+	// transaction is waited for before giving up on it
+	DefaultTxTimeout = 2 * time.Minute
+)
+
+// trackedTx is a transaction submitted through the service. It is pending
+// until the outcome of its commit is known.
+type trackedTx struct {
+	// hash of the signed ethereum transaction the transaction was
+	// submitted for, if any
+	hash      string
+	pending   bool
+	status    TxStatus
+	completed time.Time
+}
+
+// txTracker records the outcome of the transactions submitted through the
+// service, so that their receipts are not looked up on the ledger before
+// they are committed. Transactions submitted for signed ethereum
+// transactions can also be found by the hash of the ethereum transaction.
+type txTracker struct {
+	mutex     sync.Mutex
+	txs       map[string]*trackedTx
+	hashes    map[string]string
+	retention time.Duration
+	timeout   time.Duration
+}
+
+func newTxTracker(retention, timeout time.Duration) *txTracker {
+	return &txTracker{
+		txs:       make(map[string]*trackedTx),
+		hashes:    make(map[string]string),
+		retention: retention,
+		timeout:   timeout,
+	}
+}
+
+// track marks the transaction as pending until its outcome is delivered on
+// the statuses channel. If no outcome is delivered within the timeout, the
+// transaction stops being pending with an error, and it is then up to the
+// ledger to tell whether it was committed.
+func (t *txTracker) track(txID string, statuses <-chan TxStatus) {
+	t.mutex.Lock()
+	t.expire()
+	tx := &trackedTx{pending: true}
+	t.txs[txID] = tx
+	t.mutex.Unlock()
+
+	go func() {
+		timer := time.NewTimer(t.timeout)
+		defer timer.Stop()
+
+		var status TxStatus
+		select {
+		case status = <-statuses:
+		case <-timer.C:
+			status = TxStatus{Err: fmt.Errorf("no outcome of the commit received within %s", t.timeout)}
+		}
+
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		tx.pending = false
+		tx.status = status
+		tx.completed = time.Now()
+	}()
+}
+
+// alias lets the tracked transaction be found by the hash of the signed
+// ethereum transaction it was submitted for
+func (t *txTracker) alias(hash, txID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tx, ok := t.txs[txID]
+	if !ok {
+		return
+	}
+	hash = strings.ToLower(hash)
+	tx.hash = hash
+	t.hashes[hash] = txID
+}
+
+// reserve claims the hash of a signed ethereum transaction for the time it is
+// being submitted. It returns false when the hash is already claimed or
+// tracked, so that a signed transaction is only submitted once.
+func (t *txTracker) reserve(hash string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.expire()
+
+	hash = strings.ToLower(hash)
+	if _, ok := t.hashes[hash]; ok {
+		return false
+	}
+	t.hashes[hash] = ""
+	return true
+}
+
+// release drops the claim on the hash of a signed ethereum transaction that
+// was not submitted
+func (t *txTracker) release(hash string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	hash = strings.ToLower(hash)
+	if t.hashes[hash] == "" {
+		delete(t.hashes, hash)
+	}
+}
+
+// resolve returns the ID of the fabric transaction submitted for the hash of
+// an ethereum transaction. Any other hash is returned as it is.
+func (t *txTracker) resolve(hash string) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.expire()
+
+	if txID := t.hashes[strings.ToLower(hash)]; txID != "" {
+		return txID
+	}
+	return hash
+}
+
+// get returns a copy of the tracked transaction
+func (t *txTracker) get(txID string) (trackedTx, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.expire()
+
+	tx, ok := t.txs[txID]
+	if !ok {
+		return trackedTx{}, false
+	}
+	return *tx, true
+}
+
+// expire forgets the transactions whose outcome has been known for longer
+// than the retention. It must be called with the tracker mutex held.
+func (t *txTracker) expire() {
+	for id, tx := range t.txs {
+		if !tx.pending && time.Since(tx.completed) > t.retention {
+			delete(t.txs, id)
+			delete(t.hashes, tx.hash)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/protos/peer"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("txTracker", func() {
+	var tracker *txTracker
+
+	BeforeEach(func() {
+		tracker = newTxTracker(time.Minute, 50*time.Millisecond)
+	})
+
+	pending := func(txID string) func() bool {
+		return func() bool {
+			tx, ok := tracker.get(txID)
+			Expect(ok).To(BeTrue())
+			return tx.pending
+		}
+	}
+
+	It("keeps a transaction pending until its outcome is delivered", func() {
+		statuses := make(chan TxStatus, 1)
+		tracker.track("tx1", statuses)
+		Consistently(pending("tx1"), 20*time.Millisecond).Should(BeTrue())
+
+		statuses <- TxStatus{ValidationCode: peer.TxValidationCode_MVCC_READ_CONFLICT}
+		Eventually(pending("tx1")).Should(BeFalse())
+
+		tx, _ := tracker.get("tx1")
+		Expect(tx.status).To(Equal(TxStatus{ValidationCode: peer.TxValidationCode_MVCC_READ_CONFLICT}))
+	})
+
+	It("gives up on a transaction whose outcome is never delivered", func() {
+		tracker.track("tx1", make(chan TxStatus))
+		Eventually(pending("tx1")).Should(BeFalse())
+
+		tx, _ := tracker.get("tx1")
+		Expect(tx.status.Err).To(MatchError("no outcome of the commit received within 50ms"))
+	})
+
+	It("forgets transactions once their outcome is older than the retention", func() {
+		tracker = newTxTracker(10*time.Millisecond, time.Minute)
+		statuses := make(chan TxStatus, 1)
+		statuses <- TxStatus{}
+		tracker.track("tx1", statuses)
+
+		Eventually(func() bool {
+			_, ok := tracker.get("tx1")
+			return ok
+		}).Should(BeFalse())
+	})
+})
@@ -0,0 +1,303 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/peer"
+)
+
+// The evmscc is invoked with the callee address as the function name and the
+// input data as the first argument. Transactions submitted for a signed
+// ethereum transaction carry the signed transaction as a second argument, so
+// that it is recorded on the ledger along with the fabric transaction.
+const signedTxArg = 2
+
+// transactionHash returns the hash ethereum clients know an evmscc
+// transaction by: the hash of the signed ethereum transaction it was
+// submitted for, if any, and the fabric transaction ID otherwise
+func transactionHash(invokeSpec *peer.ChaincodeInvocationSpec, txID string) (types.Hash, error) {
+	args := invokeSpec.GetChaincodeSpec().GetInput().Args
+	if len(args) <= signedTxArg {
+		return txHash(txID)
+	}
+
+	raw, err := types.HexToBytes(string(args[signedTxArg]))
+	if err != nil {
+		return types.Hash{}, fmt.Errorf("invalid signed transaction of transaction %s: %s", txID, err)
+	}
+	return types.BytesToHash(keccak256(raw)), nil
+}
+
+// DefaultRawTxIndexSize is the number of signed transactions the index
+// remembers the hash of. Once it is reached, the oldest transactions are
+// forgotten and can only be found by their fabric transaction ID.
+const DefaultRawTxIndexSize = 100000
+
+// rawTxIndex finds the fabric transactions submitted for signed ethereum
+// transactions by the hash of the signed transaction. As the signed
+// transactions are on the ledger, the index is built from the blocks of the
+// channel rather than from the transactions submitted through the proxy, and
+// survives the proxy being restarted.
+//
+// The index is loaded in the background once it is first used: it follows
+// the blocks committed from then on, and reads the blocks committed before.
+// Lookups never wait for the ledger, they only see the blocks indexed so far.
+//
+// The index also keeps the next nonce of the signers of the transactions, so
+// that a nonce is only used once by a signer.
+type rawTxIndex struct {
+	backend   FabricBackend
+	channel   string
+	chaincode string
+	size      int
+
+	mutex sync.Mutex
+	load  *indexLoad
+	// height is the number of the next block to index
+	height uint64
+	// ahead holds the blocks delivered before the ones preceding them are
+	// indexed
+	ahead map[uint64]*common.Block
+	txIDs map[types.Hash]string
+	// hashes are the keys of txIDs in the order they were indexed
+	hashes []types.Hash
+	// nonces are the next nonces of the signers, after the highest nonce
+	// of their transactions on the ledger or being submitted
+	nonces map[types.Address]uint64
+}
+
+// indexLoad is an attempt at loading the index, done once the blocks
+// committed before it started are indexed
+type indexLoad struct {
+	done chan struct{}
+	err  error
+}
+
+func newRawTxIndex(backend FabricBackend, channel, chaincode string, size int) *rawTxIndex {
+	return &rawTxIndex{
+		backend:   backend,
+		channel:   channel,
+		chaincode: chaincode,
+		size:      size,
+		ahead:     make(map[uint64]*common.Block),
+		txIDs:     make(map[types.Hash]string),
+		nonces:    make(map[types.Address]uint64),
+	}
+}
+
+// start loads the index with the identity of the user, unless it is already
+// loaded or being loaded. A failed load is attempted again on the next call.
+func (x *rawTxIndex) start(user string) *indexLoad {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	if x.load == nil {
+		x.load = &indexLoad{done: make(chan struct{})}
+		go x.run(user, x.load)
+	}
+	return x.load
+}
+
+// wait starts loading the index if needed, and waits until the blocks
+// committed before it started are indexed
+func (x *rawTxIndex) wait(user string) error {
+	load := x.start(user)
+	<-load.done
+	return load.err
+}
+
+func (x *rawTxIndex) run(user string, load *indexLoad) {
+	defer close(load.done)
+
+	unsubscribe, err := x.backend.SubscribeBlocks(x.channel, user, x.index)
+	if err == nil {
+		err = x.catchUp(user)
+	}
+	if err == nil {
+		return
+	}
+
+	fmt.Printf("Failed to index the signed transactions of channel %s: %s\n", x.channel, err)
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+	load.err = err
+	x.mutex.Lock()
+	x.load = nil
+	x.mutex.Unlock()
+}
+
+// catchUp indexes the blocks committed before the subscription to the new
+// blocks started. The ledger is read without holding the index lock.
+func (x *rawTxIndex) catchUp(user string) error {
+	info, err := x.backend.GetChainInfo(x.channel, user)
+	if err != nil {
+		return err
+	}
+
+	for {
+		x.mutex.Lock()
+		next := x.height
+		x.mutex.Unlock()
+		if next >= info.GetHeight() {
+			return nil
+		}
+
+		block, err := x.backend.GetBlockByNumber(x.channel, user, next)
+		if err != nil {
+			return err
+		}
+		if number := block.GetHeader().GetNumber(); number != next {
+			return fmt.Errorf("expected block %d, got block %d", next, number)
+		}
+		x.index(block)
+	}
+}
+
+// index adds the block to the index, in the order of the blocks. A block
+// delivered ahead of the next block to index is held until its turn comes.
+func (x *rawTxIndex) index(block *common.Block) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	number := block.GetHeader().GetNumber()
+	if number < x.height {
+		return
+	}
+	x.ahead[number] = block
+
+	for {
+		next, ok := x.ahead[x.height]
+		if !ok {
+			return
+		}
+		delete(x.ahead, x.height)
+
+		if err := x.add(next); err != nil {
+			fmt.Printf("Failed to index the signed transactions of block %d: %s\n", x.height, err)
+		}
+		x.height++
+	}
+}
+
+// lookup returns the ID of the fabric transaction submitted for the signed
+// ethereum transaction of the hash, if the transaction is indexed
+func (x *rawTxIndex) lookup(hash types.Hash) (string, bool) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	txID, ok := x.txIDs[hash]
+	return txID, ok
+}
+
+// reserveNonce claims the nonce of the signer for a transaction being
+// submitted. It returns false when the signer already used the nonce or a
+// higher one, and otherwise the next nonce of the signer before the claim.
+func (x *rawTxIndex) reserveNonce(from types.Address, nonce uint64) (uint64, bool) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	next := x.nonces[from]
+	if nonce < next {
+		return next, false
+	}
+	x.nonces[from] = nonce + 1
+	return next, true
+}
+
+// releaseNonce drops the claim on the nonce of a transaction that was not
+// submitted, unless a higher nonce was used since
+func (x *rawTxIndex) releaseNonce(from types.Address, nonce, next uint64) {
+	x.mutex.Lock()
+	defer x.mutex.Unlock()
+
+	if x.nonces[from] == nonce+1 {
+		x.nonces[from] = next
+	}
+}
+
+// useNonce records the nonce of a signed transaction on the ledger. It must
+// be called with the index mutex held.
+func (x *rawTxIndex) useNonce(raw []byte) {
+	tx, err := decodeRawTransaction(raw)
+	if err != nil {
+		return
+	}
+	from, err := tx.sender()
+	if err != nil {
+		return
+	}
+	nonce, err := tx.nonce()
+	if err != nil {
+		return
+	}
+
+	if nonce >= x.nonces[from] {
+		x.nonces[from] = nonce + 1
+	}
+}
+
+// add indexes the transactions of the block submitted for signed ethereum
+// transactions. Invalidated transactions are indexed too, so that their
+// failed receipt can be returned. It must be called with the index mutex
+// held.
+func (x *rawTxIndex) add(block *common.Block) error {
+	for _, envBytes := range block.GetData().GetData() {
+		chHeader, err := getChannelHeader(envBytes)
+		if err != nil {
+			return err
+		}
+
+		if chHeader.GetType() != int32(common.HeaderType_ENDORSER_TRANSACTION) {
+			continue
+		}
+
+		env := &common.Envelope{}
+		err = proto.Unmarshal(envBytes, env)
+		if err != nil {
+			return err
+		}
+
+		_, invokeSpec, _, err := decodeTransaction(env)
+		if err != nil {
+			return err
+		}
+
+		spec := invokeSpec.GetChaincodeSpec()
+		if spec.GetChaincodeId().GetName() != x.chaincode || len(spec.GetInput().Args) <= signedTxArg {
+			continue
+		}
+
+		raw, err := types.HexToBytes(string(spec.GetInput().Args[signedTxArg]))
+		if err != nil {
+			return fmt.Errorf("invalid signed transaction of transaction %s: %s", chHeader.GetTxId(), err)
+		}
+		hash := types.BytesToHash(keccak256(raw))
+		x.useNonce(raw)
+
+		// A signed transaction included twice is known by its first
+		// inclusion
+		if _, ok := x.txIDs[hash]; ok {
+			continue
+		}
+		x.txIDs[hash] = chHeader.GetTxId()
+		x.hashes = append(x.hashes, hash)
+
+		if len(x.hashes) > x.size {
+			delete(x.txIDs, x.hashes[0])
+			x.hashes = x.hashes[1:]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,351 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package types implements the values of the ethereum JSON-RPC API and their
+// JSON encoding. Values that do not follow the encoding are rejected when
+// unmarshalled.
+//
+// Binary data (Address, Hash and Bytes) is encoded as a string of hex digits
+// with a 0x prefix, two digits per byte. The prefix is optional on input.
+// Quantities are encoded as a 0x prefixed string of hex digits without
+// leading zeros, 0x0 being zero. Quantity holds quantities of up to 64 bits,
+// such as block numbers, and Big the ones of any size, such as amounts of
+// wei.
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	// AddressLength is the length in bytes of an account address
+	AddressLength = 20
+	// HashLength is the length in bytes of a hash
+	HashLength = 32
+)
+
+// Address is the address of an account
+type Address [AddressLength]byte
+
+// Hash is a 32 byte hash, such as the hash of a transaction or a block, or a
+// log topic
+type Hash [HashLength]byte
+
+// Bytes is binary data of any length
+type Bytes []byte
+
+// Quantity is an unsigned integer of up to 64 bits
+type Quantity uint64
+
+// Big is an unsigned integer of any size
+type Big big.Int
+
+// HexToAddress decodes an address given with or without the 0x prefix
+func HexToAddress(s string) (Address, error) {
+	var a Address
+	err := decodeFixed("address", s, a[:])
+	return a, err
+}
+
+// BytesToAddress returns the address made of the last 20 bytes of b, left
+// padded with zeros when b is shorter
+func BytesToAddress(b []byte) Address {
+	var a Address
+	if len(b) > len(a) {
+		b = b[len(b)-len(a):]
+	}
+	copy(a[len(a)-len(b):], b)
+	return a
+}
+
+// String returns the 0x prefixed lowercase hex encoding of the address
+func (a Address) String() string {
+	return encode(a[:])
+}
+
+func (a Address) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalString("address", data)
+	if err != nil {
+		return err
+	}
+
+	*a, err = HexToAddress(s)
+	return err
+}
+
+// HexToHash decodes a hash given with or without the 0x prefix
+func HexToHash(s string) (Hash, error) {
+	var h Hash
+	err := decodeFixed("hash", s, h[:])
+	return h, err
+}
+
+// BytesToHash returns the hash made of the last 32 bytes of b, left padded
+// with zeros when b is shorter
+func BytesToHash(b []byte) Hash {
+	var h Hash
+	if len(b) > len(h) {
+		b = b[len(b)-len(h):]
+	}
+	copy(h[len(h)-len(b):], b)
+	return h
+}
+
+// String returns the 0x prefixed lowercase hex encoding of the hash
+func (h Hash) String() string {
+	return encode(h[:])
+}
+
+func (h Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.String())
+}
+
+func (h *Hash) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalString("hash", data)
+	if err != nil {
+		return err
+	}
+
+	*h, err = HexToHash(s)
+	return err
+}
+
+// HexToBytes decodes data given with or without the 0x prefix
+func HexToBytes(s string) (Bytes, error) {
+	b, err := hex.DecodeString(strip0x(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid data %q: %s", s, hexError(err))
+	}
+	return b, nil
+}
+
+// String returns the 0x prefixed lowercase hex encoding of the data
+func (b Bytes) String() string {
+	return encode(b)
+}
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalString("data", data)
+	if err != nil {
+		return err
+	}
+
+	*b, err = HexToBytes(s)
+	return err
+}
+
+// ParseQuantity decodes a 0x prefixed quantity
+func ParseQuantity(s string) (Quantity, error) {
+	digits, err := quantityDigits(s)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: exceeds 64 bits", s)
+	}
+	return Quantity(n), nil
+}
+
+// String returns the 0x prefixed hex encoding of the quantity
+func (q Quantity) String() string {
+	return "0x" + strconv.FormatUint(uint64(q), 16)
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalString("quantity", data)
+	if err != nil {
+		return err
+	}
+
+	*q, err = ParseQuantity(s)
+	return err
+}
+
+// NewBig returns the Big of n, which must not be negative
+func NewBig(n *big.Int) *Big {
+	return (*Big)(new(big.Int).Set(n))
+}
+
+// ParseBig decodes a 0x prefixed quantity of any size
+func ParseBig(s string) (*Big, error) {
+	digits, err := quantityDigits(s)
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := new(big.Int).SetString(digits, 16)
+	return (*Big)(n), nil
+}
+
+// ToInt returns the value of the quantity as a big.Int
+func (b *Big) ToInt() *big.Int {
+	return (*big.Int)(b)
+}
+
+// String returns the 0x prefixed hex encoding of the quantity
+func (b *Big) String() string {
+	return "0x" + b.ToInt().Text(16)
+}
+
+func (b Big) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *Big) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalString("quantity", data)
+	if err != nil {
+		return err
+	}
+
+	n, err := ParseBig(s)
+	if err != nil {
+		return err
+	}
+	*b = *n
+	return nil
+}
+
+// The block tags that can be given instead of a block number. Fabric has no
+// pending block, the pending tag designates the latest block.
+const (
+	LatestBlock   = "latest"
+	EarliestBlock = "earliest"
+	PendingBlock  = "pending"
+)
+
+// BlockNumberOrTag is either a block number or one of the block tags
+type BlockNumberOrTag struct {
+	// Tag is the block tag, or empty for a block number
+	Tag    string
+	Number Quantity
+}
+
+// BlockNumber returns the BlockNumberOrTag of the block number
+func BlockNumber(n uint64) BlockNumberOrTag {
+	return BlockNumberOrTag{Number: Quantity(n)}
+}
+
+// Tagged returns the BlockNumberOrTag of the block tag
+func Tagged(tag string) BlockNumberOrTag {
+	return BlockNumberOrTag{Tag: tag}
+}
+
+// ParseBlockNumberOrTag decodes a block tag or a block number
+func ParseBlockNumberOrTag(s string) (BlockNumberOrTag, error) {
+	switch s {
+	case LatestBlock, EarliestBlock, PendingBlock:
+		return Tagged(s), nil
+	}
+
+	n, err := ParseQuantity(s)
+	if err != nil {
+		return BlockNumberOrTag{}, fmt.Errorf("invalid block number %q: expected a quantity or one of latest, earliest and pending", s)
+	}
+	return BlockNumberOrTag{Number: n}, nil
+}
+
+// String returns the tag, or the encoding of the block number
+func (b BlockNumberOrTag) String() string {
+	if b.Tag != "" {
+		return b.Tag
+	}
+	return b.Number.String()
+}
+
+func (b BlockNumberOrTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *BlockNumberOrTag) UnmarshalJSON(data []byte) error {
+	s, err := unmarshalString("block number", data)
+	if err != nil {
+		return err
+	}
+
+	*b, err = ParseBlockNumberOrTag(s)
+	return err
+}
+
+func encode(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+func strip0x(s string) string {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		return s[2:]
+	}
+	return s
+}
+
+// quantityDigits returns the hex digits of a quantity, checking that they
+// have the 0x prefix and no leading zero
+func quantityDigits(s string) (string, error) {
+	if len(s) < 2 || s[:2] != "0x" {
+		return "", fmt.Errorf("invalid quantity %q: missing 0x prefix", s)
+	}
+
+	digits := s[2:]
+	switch {
+	case digits == "":
+		return "", fmt.Errorf("invalid quantity %q: no digits", s)
+	case len(digits) > 1 && digits[0] == '0':
+		return "", fmt.Errorf("invalid quantity %q: leading zero", s)
+	}
+
+	for _, c := range digits {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return "", fmt.Errorf("invalid quantity %q: invalid hex digit", s)
+		}
+	}
+	return digits, nil
+}
+
+// decodeFixed decodes s into out, which it must exactly fill
+func decodeFixed(kind, s string, out []byte) error {
+	b, err := hex.DecodeString(strip0x(s))
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %s", kind, s, hexError(err))
+	}
+	if len(b) != len(out) {
+		return fmt.Errorf("invalid %s %q: expected %d bytes, got %d", kind, s, len(out), len(b))
+	}
+	copy(out, b)
+	return nil
+}
+
+func hexError(err error) string {
+	if err == hex.ErrLength {
+		return "odd number of hex digits"
+	}
+	return "invalid hex digit"
+}
+
+func unmarshalString(kind string, data []byte) (string, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return "", errors.New("invalid " + kind + ": expected a hex string")
+	}
+	return s, nil
+}
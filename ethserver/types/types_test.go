@@ -0,0 +1,185 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package types_test
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/hyperledger/fabric-chaincode-evm/ethserver/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Types", func() {
+	Context("Address", func() {
+		It("round trips through JSON", func() {
+			var a types.Address
+			Expect(json.Unmarshal([]byte(`"0x00000000000000000000000000000000000012Ab"`), &a)).To(Succeed())
+			Expect(a).To(Equal(types.BytesToAddress([]byte{0x12, 0xab})))
+
+			encoded, err := json.Marshal(a)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"0x00000000000000000000000000000000000012ab"`))
+		})
+
+		It("accepts addresses without the 0x prefix", func() {
+			a, err := types.HexToAddress("00000000000000000000000000000000000012ab")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(a.String()).To(Equal("0x00000000000000000000000000000000000012ab"))
+		})
+
+		It("rejects malformed addresses", func() {
+			var a types.Address
+			Expect(json.Unmarshal([]byte(`"0x1234"`), &a)).To(MatchError(`invalid address "0x1234": expected 20 bytes, got 2`))
+			Expect(json.Unmarshal([]byte(`"0x0000000000000000000000000000000000012ab"`), &a)).To(MatchError(`invalid address "0x0000000000000000000000000000000000012ab": odd number of hex digits`))
+			Expect(json.Unmarshal([]byte(`"0x0x000000000000000000000000000000000012ab"`), &a)).To(MatchError(`invalid address "0x0x000000000000000000000000000000000012ab": invalid hex digit`))
+			Expect(json.Unmarshal([]byte(`1234`), &a)).To(MatchError("invalid address: expected a hex string"))
+		})
+
+		It("keeps the last 20 bytes of longer values", func() {
+			b := make([]byte, 32)
+			b[11], b[12], b[31] = 0xff, 0x01, 0x02
+			a := types.BytesToAddress(b)
+			Expect(a[0]).To(Equal(byte(0x01)))
+			Expect(a[19]).To(Equal(byte(0x02)))
+		})
+	})
+
+	Context("Hash", func() {
+		It("round trips through JSON", func() {
+			hex := "0x00000000000000000000000000000000000000000000000000000000000000ff"
+			var h types.Hash
+			Expect(json.Unmarshal([]byte(`"`+hex+`"`), &h)).To(Succeed())
+			Expect(h).To(Equal(types.BytesToHash([]byte{0xff})))
+
+			encoded, err := json.Marshal(h)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"` + hex + `"`))
+		})
+
+		It("rejects hashes of the wrong length", func() {
+			_, err := types.HexToHash("0xabcd")
+			Expect(err).To(MatchError(`invalid hash "0xabcd": expected 32 bytes, got 2`))
+		})
+	})
+
+	Context("Bytes", func() {
+		It("round trips through JSON", func() {
+			var b types.Bytes
+			Expect(json.Unmarshal([]byte(`"0xabCD"`), &b)).To(Succeed())
+			Expect(b).To(Equal(types.Bytes{0xab, 0xcd}))
+
+			encoded, err := json.Marshal(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"0xabcd"`))
+		})
+
+		It("encodes empty data as 0x", func() {
+			Expect(types.Bytes{}.String()).To(Equal("0x"))
+			Expect(types.HexToBytes("0x")).To(BeEmpty())
+		})
+
+		It("rejects malformed data", func() {
+			_, err := types.HexToBytes("0xabc")
+			Expect(err).To(MatchError(`invalid data "0xabc": odd number of hex digits`))
+			_, err = types.HexToBytes("0x0x12")
+			Expect(err).To(MatchError(`invalid data "0x0x12": invalid hex digit`))
+		})
+	})
+
+	Context("Quantity", func() {
+		It("round trips through JSON", func() {
+			var q types.Quantity
+			Expect(json.Unmarshal([]byte(`"0x1f"`), &q)).To(Succeed())
+			Expect(q).To(Equal(types.Quantity(31)))
+
+			encoded, err := json.Marshal(q)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"0x1f"`))
+		})
+
+		It("encodes zero as 0x0", func() {
+			Expect(types.Quantity(0).String()).To(Equal("0x0"))
+			Expect(types.ParseQuantity("0x0")).To(Equal(types.Quantity(0)))
+		})
+
+		It("rejects malformed quantities", func() {
+			for input, message := range map[string]string{
+				"1f":                  `invalid quantity "1f": missing 0x prefix`,
+				"0x":                  `invalid quantity "0x": no digits`,
+				"0x01":                `invalid quantity "0x01": leading zero`,
+				"0xg":                 `invalid quantity "0xg": invalid hex digit`,
+				"0x10000000000000000": `invalid quantity "0x10000000000000000": exceeds 64 bits`,
+			} {
+				_, err := types.ParseQuantity(input)
+				Expect(err).To(MatchError(message))
+			}
+		})
+	})
+
+	Context("Big", func() {
+		It("round trips quantities beyond 64 bits through JSON", func() {
+			var b types.Big
+			Expect(json.Unmarshal([]byte(`"0x3635c9adc5dea00000"`), &b)).To(Succeed())
+
+			thousandEther, _ := new(big.Int).SetString("1000000000000000000000", 10)
+			Expect(b.ToInt()).To(Equal(thousandEther))
+
+			encoded, err := json.Marshal(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"0x3635c9adc5dea00000"`))
+		})
+
+		It("encodes zero as 0x0", func() {
+			encoded, err := json.Marshal(types.Big{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"0x0"`))
+			Expect(types.NewBig(big.NewInt(0)).String()).To(Equal("0x0"))
+		})
+
+		It("rejects malformed quantities", func() {
+			for input, message := range map[string]string{
+				"1f":    `invalid quantity "1f": missing 0x prefix`,
+				"0x":    `invalid quantity "0x": no digits`,
+				"0x01":  `invalid quantity "0x01": leading zero`,
+				"0xg":   `invalid quantity "0xg": invalid hex digit`,
+				"0x-1":  `invalid quantity "0x-1": invalid hex digit`,
+				"0x1_0": `invalid quantity "0x1_0": invalid hex digit`,
+			} {
+				_, err := types.ParseBig(input)
+				Expect(err).To(MatchError(message))
+			}
+		})
+	})
+
+	Context("BlockNumberOrTag", func() {
+		It("decodes block tags", func() {
+			for _, tag := range []string{types.LatestBlock, types.EarliestBlock, types.PendingBlock} {
+				var b types.BlockNumberOrTag
+				Expect(json.Unmarshal([]byte(`"`+tag+`"`), &b)).To(Succeed())
+				Expect(b).To(Equal(types.Tagged(tag)))
+			}
+		})
+
+		It("decodes block numbers", func() {
+			var b types.BlockNumberOrTag
+			Expect(json.Unmarshal([]byte(`"0x2a"`), &b)).To(Succeed())
+			Expect(b).To(Equal(types.BlockNumber(42)))
+
+			encoded, err := json.Marshal(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoded).To(MatchJSON(`"0x2a"`))
+		})
+
+		It("rejects anything else", func() {
+			var b types.BlockNumberOrTag
+			Expect(json.Unmarshal([]byte(`"safe"`), &b)).To(MatchError(`invalid block number "safe": expected a quantity or one of latest, earliest and pending`))
+			Expect(json.Unmarshal([]byte(`42`), &b)).To(MatchError("invalid block number: expected a hex string"))
+		})
+	})
+})
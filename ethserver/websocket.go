@@ -0,0 +1,404 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ethserver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/rpc/v2/json2"
+	"github.com/gorilla/websocket"
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+const (
+	// wsWriteTimeout is how long writing a message to a websocket client may
+	// take before the connection is closed
+	wsWriteTimeout = 10 * time.Second
+	// wsQueueSize is how many messages may wait to be written to a websocket
+	// client. A client that falls further behind is disconnected.
+	wsQueueSize = 256
+)
+
+var upgrader = websocket.Upgrader{
+	// Browser based dapps connect from arbitrary origins, the same way the
+	// HTTP endpoint allows any origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type wsRequest struct {
+	Version string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params"`
+}
+
+type wsResponse struct {
+	Version string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *json2.Error     `json:"error,omitempty"`
+}
+
+type wsNotification struct {
+	Version string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// wsConnection serves JSON-RPC requests received over a websocket. Regular
+// methods are dispatched to the same RPC handler used for HTTP, while
+// eth_subscribe and eth_unsubscribe are handled on the connection itself as
+// their notifications are pushed to the client.
+//
+// The messages to the client are queued and written by a goroutine of the
+// connection, so that a slow client does not hold up the delivery of the
+// blocks to the subscriptions of the other connections.
+type wsConnection struct {
+	conn    *websocket.Conn
+	eth     *EthRPCService
+	handler http.Handler
+	// header of the handshake, the identity it selects is used for every
+	// request of the connection
+	header http.Header
+
+	// queueMutex orders the messages queued for the client
+	queueMutex sync.Mutex
+	queue      chan []byte
+	closed     chan struct{}
+
+	subsMutex     sync.Mutex
+	subscriptions map[string]func()
+}
+
+// websocketHandler upgrades websocket requests to connections served by the
+// service and passes any other request on to the RPC handler
+func (s *EthServer) websocketHandler(eth *EthRPCService, rpcHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			rpcHandler.ServeHTTP(w, r)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			fmt.Printf("Failed to upgrade websocket connection: %s\n", err)
+			return
+		}
+
+		conn.SetReadLimit(maxRequestSize)
+
+		c := &wsConnection{
+			conn:          conn,
+			eth:           eth,
+			handler:       rpcHandler,
+			header:        r.Header,
+			queue:         make(chan []byte, wsQueueSize),
+			closed:        make(chan struct{}),
+			subscriptions: make(map[string]func()),
+		}
+
+		s.mutex.Lock()
+		s.wsConns[c] = struct{}{}
+		s.mutex.Unlock()
+
+		c.serve()
+
+		s.mutex.Lock()
+		delete(s.wsConns, c)
+		s.mutex.Unlock()
+	})
+}
+
+func (c *wsConnection) serve() {
+	defer c.close()
+	go c.writeQueued()
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				fmt.Printf("Websocket connection closed: %s\n", err)
+			}
+			return
+		}
+
+		c.handleMessage(msg)
+	}
+}
+
+func (c *wsConnection) close() {
+	c.subsMutex.Lock()
+	for id, unsubscribe := range c.subscriptions {
+		unsubscribe()
+		delete(c.subscriptions, id)
+	}
+	c.subsMutex.Unlock()
+
+	close(c.closed)
+	c.conn.Close()
+}
+
+// writeQueued writes the queued messages to the client until the connection
+// is closed. A client that does not accept a message within the write timeout
+// is disconnected.
+func (c *wsConnection) writeQueued() {
+	for {
+		select {
+		case msg := <-c.queue:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				fmt.Printf("Failed to write websocket message: %s\n", err)
+				c.conn.Close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *wsConnection) handleMessage(msg []byte) {
+	req := wsRequest{}
+	if err := json.Unmarshal(msg, &req); err == nil {
+		switch req.Method {
+		case "eth_subscribe":
+			c.subscribe(req)
+			return
+		case "eth_unsubscribe":
+			c.unsubscribe(req)
+			return
+		}
+	}
+
+	c.dispatch(msg)
+}
+
+// dispatch runs the message through the RPC handler as if it was posted over
+// HTTP and writes back the response, if any
+func (c *wsConnection) dispatch(msg []byte) {
+	r, err := http.NewRequest("POST", "/", bytes.NewReader(msg))
+	if err != nil {
+		fmt.Printf("Failed to create request: %s\n", err)
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if user := c.header.Get(UserHeader); user != "" {
+		r.Header.Set(UserHeader, user)
+	}
+
+	w := newResponseBuffer()
+	c.handler.ServeHTTP(w, r)
+
+	if w.body.Len() > 0 {
+		c.write(bytes.TrimSpace(w.body.Bytes()))
+	}
+}
+
+// params decodes the parameters of an eth_subscribe or eth_unsubscribe
+// request, which start with a string: the subscription type or id
+func (req wsRequest) params() (string, []json.RawMessage, error) {
+	var params []json.RawMessage
+	if err := json.Unmarshal(req.Params, &params); err != nil || len(params) == 0 {
+		return "", nil, fmt.Errorf("invalid parameters of %s: expected an array starting with a string", req.Method)
+	}
+
+	var first string
+	if err := json.Unmarshal(params[0], &first); err != nil {
+		return "", nil, fmt.Errorf("invalid parameters of %s: expected an array starting with a string", req.Method)
+	}
+	return first, params[1:], nil
+}
+
+func (c *wsConnection) subscribe(req wsRequest) {
+	kind, params, err := req.params()
+	if err != nil {
+		c.writeError(req.ID, json2.E_BAD_PARAMS, err)
+		return
+	}
+
+	var criteria FilterCriteria
+	var callback func(id string) func(*common.Block)
+
+	switch kind {
+	case "newHeads":
+		callback = func(id string) func(*common.Block) {
+			return func(block *common.Block) {
+				header, err := newBlock(block, c.eth.chaincode.Name, false)
+				if err != nil {
+					fmt.Printf("Failed to convert block: %s\n", err)
+					return
+				}
+				c.notify(id, header)
+			}
+		}
+	case "logs":
+		if len(params) > 0 {
+			if err := json.Unmarshal(params[0], &criteria); err != nil {
+				c.writeError(req.ID, json2.E_BAD_PARAMS, err)
+				return
+			}
+		}
+		callback = func(id string) func(*common.Block) {
+			return func(block *common.Block) {
+				logs, err := blockLogs(block, c.eth.chaincode.Name)
+				if err != nil {
+					fmt.Printf("Failed to get logs of block: %s\n", err)
+					return
+				}
+				for _, log := range logs {
+					if criteria.Matches(log) {
+						c.notify(id, log)
+					}
+				}
+			}
+		}
+	default:
+		c.writeError(req.ID, json2.E_BAD_PARAMS, fmt.Errorf("unsupported subscription type %q", kind))
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		c.writeError(req.ID, json2.E_INTERNAL, err)
+		return
+	}
+	id := "0x" + hex.EncodeToString(idBytes)
+
+	// Hold the queue lock until the response is queued so no notification
+	// of the subscription can reach the client before the subscription id
+	// does
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+
+	user, err := c.eth.requestUser(&http.Request{Header: c.header})
+	if err != nil {
+		c.send(errorResponse(req.ID, json2.E_SERVER, err))
+		return
+	}
+
+	unsubscribe, err := c.eth.backend.SubscribeBlocks(c.eth.channel, user, callback(id))
+	if err != nil {
+		c.send(errorResponse(req.ID, json2.E_SERVER, err))
+		return
+	}
+
+	c.subsMutex.Lock()
+	c.subscriptions[id] = unsubscribe
+	c.subsMutex.Unlock()
+
+	c.send(resultResponse(req.ID, id))
+}
+
+func (c *wsConnection) unsubscribe(req wsRequest) {
+	id, _, err := req.params()
+	if err != nil {
+		c.writeError(req.ID, json2.E_BAD_PARAMS, err)
+		return
+	}
+
+	c.subsMutex.Lock()
+	unsubscribe, ok := c.subscriptions[id]
+	delete(c.subscriptions, id)
+	c.subsMutex.Unlock()
+
+	if ok {
+		unsubscribe()
+	}
+
+	c.writeResult(req.ID, ok)
+}
+
+func (c *wsConnection) notify(id string, result interface{}) {
+	c.writeJSON(wsNotification{
+		Version: json2.Version,
+		Method:  "eth_subscription",
+		Params: subscriptionResult{
+			Subscription: id,
+			Result:       result,
+		},
+	})
+}
+
+func (c *wsConnection) writeResult(id *json.RawMessage, result interface{}) {
+	c.writeJSON(resultResponse(id, result))
+}
+
+func (c *wsConnection) writeError(id *json.RawMessage, code json2.ErrorCode, err error) {
+	c.writeJSON(errorResponse(id, code, err))
+}
+
+func (c *wsConnection) writeJSON(v interface{}) {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	c.send(v)
+}
+
+func (c *wsConnection) write(msg []byte) {
+	c.queueMutex.Lock()
+	defer c.queueMutex.Unlock()
+	c.enqueue(msg)
+}
+
+// send queues the message as JSON. It must be called with the queue mutex
+// held.
+func (c *wsConnection) send(v interface{}) {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		fmt.Printf("Failed to encode websocket message: %s\n", err)
+		return
+	}
+	c.enqueue(msg)
+}
+
+// enqueue queues the message for the client, or disconnects the client when
+// its queue is full. It never blocks, and must be called with the queue mutex
+// held.
+func (c *wsConnection) enqueue(msg []byte) {
+	select {
+	case <-c.closed:
+		return
+	default:
+	}
+
+	select {
+	case c.queue <- msg:
+	default:
+		fmt.Println("Closing websocket connection falling behind its messages")
+		c.conn.Close()
+	}
+}
+
+func resultResponse(id *json.RawMessage, result interface{}) wsResponse {
+	return wsResponse{
+		Version: json2.Version,
+		ID:      id,
+		Result:  result,
+	}
+}
+
+func errorResponse(id *json.RawMessage, code json2.ErrorCode, err error) wsResponse {
+	return wsResponse{
+		Version: json2.Version,
+		ID:      id,
+		Error: &json2.Error{
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
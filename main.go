@@ -7,32 +7,134 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-evm/ethserver"
 )
 
+// shutdownTimeout is how long in flight requests are given to complete when
+// the proxy is asked to stop
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	configFile := os.Getenv("ETHSERVER_CONFIG")
-	user := os.Getenv("ETHSERVER_USER")
-	if user == "" {
-		user = "User1"
+	// The proxy holds every identity listed, the first one is the default
+	// sender of requests
+	users := splitList(os.Getenv("ETHSERVER_USER"))
+	if len(users) == 0 {
+		users = []string{"User1"}
+	}
+
+	// Every channel listed is served at /channel/<name>, the first one is
+	// also served at the root path
+	channels := splitList(os.Getenv("ETHSERVER_CHANNEL"))
+	if len(channels) == 0 {
+		channels = []string{"channel1"}
+	}
+
+	// The EVM chaincode of every channel, unless a channel is given as
+	// <channel>=<chaincode>
+	chaincode := os.Getenv("ETHSERVER_CHAINCODE")
+	if chaincode == "" {
+		chaincode = ethserver.DefaultChaincode
 	}
 
-	channel := os.Getenv("ETHSERVER_CHANNEL")
-	if channel == "" {
-		channel = "channel1"
+	// Addresses of ethereum keys whose signed transactions are submitted
+	// with one of the identities, given as <address>=<user>
+	var registered [][]string
+	for _, account := range splitList(os.Getenv("ETHSERVER_ACCOUNTS")) {
+		fields := strings.SplitN(account, "=", 2)
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		registered = append(registered, fields)
 	}
 
-	ethService := ethserver.NewEthService(configFile, user, channel)
-	server := ethserver.NewEthServer(ethService)
+	// Signed transactions are only accepted when they are replay protected
+	// with the chain ID of their channel, if it has one. The chain ID of a
+	// channel is given as <channel>=<chain ID>, or as a single chain ID when
+	// serving one channel.
+	chainIDs := make(map[string]uint64)
+	for _, entry := range splitList(os.Getenv("ETHSERVER_CHAIN_ID")) {
+		channel, id := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			channel, id = strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		}
+
+		chainID, err := strconv.ParseUint(id, 10, 64)
+		if err != nil || chainID == 0 {
+			fmt.Printf("Error parsing ETHSERVER_CHAIN_ID: invalid chain ID %q\n", id)
+			os.Exit(1)
+		}
+		chainIDs[channel] = chainID
+	}
+	if _, ok := chainIDs[""]; ok && len(channels) > 1 {
+		fmt.Println("Error parsing ETHSERVER_CHAIN_ID: expected <channel>=<chain ID> entries when serving several channels")
+		os.Exit(1)
+	}
+
+	backend, err := ethserver.NewFabricBackend(configFile)
+	if err != nil {
+		fmt.Printf("Error connecting to the fabric network: %s\n", err)
+		os.Exit(1)
+	}
+
+	var services []*ethserver.EthRPCService
+	chainChannels := make(map[uint64]string)
+	for _, channel := range channels {
+		cc := chaincode
+		if i := strings.Index(channel, "="); i >= 0 {
+			channel, cc = strings.TrimSpace(channel[:i]), strings.TrimSpace(channel[i+1:])
+		}
+
+		chainID, ok := chainIDs[channel]
+		if !ok {
+			chainID = chainIDs[""]
+		}
+		// A transaction signed for one channel must not be accepted on
+		// another one
+		if len(registered) > 0 && len(channels) > 1 {
+			if chainID == 0 {
+				fmt.Printf("Error: channel %s needs its own chain ID in ETHSERVER_CHAIN_ID to accept signed transactions\n", channel)
+				os.Exit(1)
+			}
+			if other, ok := chainChannels[chainID]; ok {
+				fmt.Printf("Error: channels %s and %s have the same chain ID %d\n", other, channel, chainID)
+				os.Exit(1)
+			}
+			chainChannels[chainID] = channel
+		}
+
+		service := ethserver.NewEthService(backend, users, channel, ethserver.ParseChaincode(cc))
+		service.SetChainID(chainID)
+		if err := service.CheckChaincode(); err != nil {
+			fmt.Printf("Error checking the EVM chaincode: %s\n", err)
+			os.Exit(1)
+		}
+		for _, account := range registered {
+			if len(account) != 2 {
+				fmt.Printf("Error registering account %s: expected <address>=<user>\n", account[0])
+				os.Exit(1)
+			}
+			if err := service.RegisterAccount(account[0], account[1]); err != nil {
+				fmt.Printf("Error registering account %s: %s\n", account[0], err)
+				os.Exit(1)
+			}
+		}
+		services = append(services, service)
+	}
+	server := ethserver.NewEthServer(services[0], services[1:]...)
 
 	var portNumber int
 	port := os.Getenv("PORT")
 	if port != "" {
-		var err error
 		portNumber, err = strconv.Atoi(port)
 		if err != nil {
 			panic("Error converting value of environment variable PORT to int")
@@ -41,7 +143,38 @@ func main() {
 		portNumber = 5000
 	}
 
+	stopped := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer close(stopped)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			fmt.Printf("Error stopping the server: %s\n", err)
+		}
+	}()
+
 	fmt.Printf("Starting server at http://0.0.0.0:%d", portNumber)
 
-	server.Start(portNumber)
+	if err := server.Start(portNumber); err != nil {
+		fmt.Printf("Error running the server: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Start returns as soon as the shutdown begins, wait for it to complete
+	<-stopped
+}
+
+// splitList returns the entries of a comma separated list, without the
+// surrounding whitespace and leaving out empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
 }
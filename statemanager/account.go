@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/burrow/account"
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"golang.org/x/crypto/sha3"
+)
+
+// accountRecordVersion is the version of the encoding of account records.
+// Records start with the version they were encoded with, so that the
+// encoding can evolve while the records already on the ledger stay readable.
+const accountRecordVersion byte = 1
+
+// accountRecord is the state of an account as persisted in the world state.
+// The nonce of an account is its burrow sequence.
+type accountRecord struct {
+	Code        []byte                    `json:"code"`
+	CodeHash    []byte                    `json:"codeHash"`
+	Nonce       uint64                    `json:"nonce"`
+	Balance     uint64                    `json:"balance"`
+	Permissions ptypes.AccountPermissions `json:"permissions"`
+}
+
+// encodeAccount returns the versioned encoding of the account record
+func encodeAccount(acc account.Account) ([]byte, error) {
+	code := acc.Code().Bytes()
+	record := accountRecord{
+		Code:        code,
+		CodeHash:    codeHash(code),
+		Nonce:       acc.Sequence(),
+		Balance:     acc.Balance(),
+		Permissions: acc.Permissions(),
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{accountRecordVersion}, data...), nil
+}
+
+// decodeAccount decodes the account record of the address
+func decodeAccount(address account.Address, data []byte) (account.Account, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty account record")
+	}
+
+	var record accountRecord
+	switch version := data[0]; version {
+	case accountRecordVersion:
+		if err := json.Unmarshal(data[1:], &record); err != nil {
+			return nil, fmt.Errorf("invalid account record: %s", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported account record version %d", version)
+	}
+
+	if !bytes.Equal(record.CodeHash, codeHash(record.Code)) {
+		return nil, fmt.Errorf("code hash of account %s does not match its code", address)
+	}
+
+	acc := account.ConcreteAccount{
+		Address:     address,
+		Sequence:    record.Nonce,
+		Balance:     record.Balance,
+		Code:        convertToBytecode(record.Code),
+		Permissions: record.Permissions,
+	}
+
+	return acc.Account(), nil
+}
+
+// codeHash returns the keccak256 hash of the code, the way ethereum hashes
+// contract code
+func codeHash(code []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(code)
+	return h.Sum(nil)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/burrow/account"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("account records", func() {
+	var addr account.Address
+
+	BeforeEach(func() {
+		addr = account.Address{0x01}
+	})
+
+	It("prefixes records with the encoding version", func() {
+		acc := account.ConcreteAccount{Address: addr, Sequence: 1}
+		data, err := encodeAccount(acc.Account())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data[0]).To(Equal(accountRecordVersion))
+	})
+
+	It("records the keccak256 hash of the code", func() {
+		acc := account.ConcreteAccount{Address: addr, Code: []byte{0x60, 0x80}}
+		data, err := encodeAccount(acc.Account())
+		Expect(err).ToNot(HaveOccurred())
+
+		var record accountRecord
+		Expect(json.Unmarshal(data[1:], &record)).To(Succeed())
+		Expect(record.Code).To(Equal([]byte{0x60, 0x80}))
+		Expect(record.CodeHash).To(Equal(codeHash([]byte{0x60, 0x80})))
+		Expect(record.CodeHash).To(HaveLen(32))
+	})
+
+	It("hashes empty code the way ethereum does", func() {
+		Expect(codeHash(nil)).To(Equal([]byte{
+			0xc5, 0xd2, 0x46, 0x01, 0x86, 0xf7, 0x23, 0x3c, 0x92, 0x7e, 0x7d, 0xb2, 0xdc, 0xc7, 0x03, 0xc0,
+			0xe5, 0x00, 0xb6, 0x53, 0xca, 0x82, 0x27, 0x3b, 0x7b, 0xfa, 0xd8, 0x04, 0x5d, 0x85, 0xa4, 0x70,
+		}))
+	})
+
+	It("rejects records of unknown versions", func() {
+		_, err := decodeAccount(addr, []byte{0x02, '{', '}'})
+		Expect(err).To(MatchError("unsupported account record version 2"))
+
+		_, err = decodeAccount(addr, nil)
+		Expect(err).To(MatchError("empty account record"))
+	})
+
+	It("rejects records whose code does not match the code hash", func() {
+		record, err := json.Marshal(accountRecord{Code: []byte{0x60}, CodeHash: codeHash([]byte{0x61})})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = decodeAccount(addr, append([]byte{accountRecordVersion}, record...))
+		Expect(err).To(MatchError("code hash of account " + addr.String() + " does not match its code"))
+	})
+})
@@ -0,0 +1,272 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/binary"
+)
+
+// Cache is a StateWriter buffering the changes of a single EVM execution in
+// memory. Reads are served from the buffer, so the execution reads its own
+// writes, and each account or slot is only read once from the underlying
+// StateWriter. The changes reach the underlying StateWriter when the cache is
+// flushed.
+//
+// The changes are journaled, so that the changes made by a failed call can be
+// reverted without reverting the changes of its caller: a snapshot is taken
+// before each call, and the cache is reverted to it when the call fails.
+//
+// A Cache is not safe for concurrent use.
+type Cache struct {
+	backend  StateWriter
+	accounts map[account.Address]*cachedAccount
+	storage  map[account.Address]*cachedStorage
+	// journal holds the functions undoing the changes made to the cache, in
+	// the order the changes were made
+	journal []func()
+}
+
+type cachedAccount struct {
+	// account is nil when the account does not exist
+	account account.Account
+	// updated is set when the account has to be written back
+	updated bool
+	// removed is set when the account has to be removed from the backend
+	// before it is written back
+	removed bool
+}
+
+type cachedStorage struct {
+	// cleared is set once the account is removed, its slots are then zero
+	// without being read from the backend
+	cleared bool
+	slots   map[binary.Word256]*cachedSlot
+}
+
+type cachedSlot struct {
+	value   binary.Word256
+	updated bool
+}
+
+// NewCache returns an empty cache in front of the backend
+func NewCache(backend StateWriter) *Cache {
+	return &Cache{
+		backend:  backend,
+		accounts: make(map[account.Address]*cachedAccount),
+		storage:  make(map[account.Address]*cachedStorage),
+	}
+}
+
+func (c *Cache) GetAccount(address account.Address) (account.Account, error) {
+	if cached, ok := c.accounts[address]; ok {
+		return cached.account, nil
+	}
+
+	acc, err := c.backend.GetAccount(address)
+	if err != nil {
+		return nil, err
+	}
+
+	c.accounts[address] = &cachedAccount{account: acc}
+	return acc, nil
+}
+
+func (c *Cache) UpdateAccount(updatedAccount account.Account) error {
+	// The account is copied so that later changes made by the caller do not
+	// leak into the buffer
+	acc := account.AsConcreteAccount(updatedAccount).Copy()
+	acc.Code = convertToBytecode(acc.Code)
+
+	// An account removed earlier is still removed from the backend before
+	// being written back
+	cached := &cachedAccount{account: acc.Account(), updated: true}
+	if prev, ok := c.accounts[acc.Address]; ok {
+		cached.removed = prev.removed
+	}
+
+	c.journalAccount(acc.Address)
+	c.accounts[acc.Address] = cached
+	return nil
+}
+
+// RemoveAccount removes the account along with all of its storage
+func (c *Cache) RemoveAccount(address account.Address) error {
+	c.journalAccount(address)
+	c.journalStorage(address)
+
+	c.accounts[address] = &cachedAccount{removed: true}
+	c.storage[address] = &cachedStorage{
+		cleared: true,
+		slots:   make(map[binary.Word256]*cachedSlot),
+	}
+	return nil
+}
+
+func (c *Cache) GetStorage(address account.Address, key binary.Word256) (binary.Word256, error) {
+	storage := c.accountStorage(address)
+	if slot, ok := storage.slots[key]; ok {
+		return slot.value, nil
+	}
+
+	if storage.cleared {
+		return binary.Word256{}, nil
+	}
+
+	value, err := c.backend.GetStorage(address, key)
+	if err != nil {
+		return binary.Word256{}, err
+	}
+
+	storage.slots[key] = &cachedSlot{value: value}
+	return value, nil
+}
+
+func (c *Cache) SetStorage(address account.Address, key, value binary.Word256) error {
+	slots := c.accountStorage(address).slots
+	prev, ok := slots[key]
+	c.journal = append(c.journal, func() {
+		if ok {
+			slots[key] = prev
+		} else {
+			delete(slots, key)
+		}
+	})
+
+	slots[key] = &cachedSlot{value: value, updated: true}
+	return nil
+}
+
+// Snapshot returns the identifier of the current state of the cache, to
+// revert to with RevertToSnapshot
+func (c *Cache) Snapshot() int {
+	return len(c.journal)
+}
+
+// RevertToSnapshot undoes the changes made since the snapshot was taken. The
+// snapshots taken after it can no longer be reverted to, and neither can any
+// snapshot once the cache is flushed.
+func (c *Cache) RevertToSnapshot(snapshot int) {
+	if snapshot < 0 || snapshot > len(c.journal) {
+		panic(fmt.Sprintf("snapshot %d cannot be reverted to", snapshot))
+	}
+
+	for i := len(c.journal) - 1; i >= snapshot; i-- {
+		c.journal[i]()
+	}
+	c.journal = c.journal[:snapshot]
+}
+
+// Flush writes the buffered changes to the underlying StateWriter and empties
+// the cache. The writes are made in a deterministic order, so that every
+// endorser of a transaction produces the same write set: accounts in the order
+// of their addresses, each account being removed, then updated, then having
+// its slots set in the order of their keys.
+func (c *Cache) Flush() error {
+	for _, address := range c.addresses() {
+		if cached, ok := c.accounts[address]; ok {
+			if cached.removed {
+				if err := c.backend.RemoveAccount(address); err != nil {
+					return err
+				}
+			}
+
+			if cached.updated {
+				if err := c.backend.UpdateAccount(cached.account); err != nil {
+					return err
+				}
+			}
+		}
+
+		storage, ok := c.storage[address]
+		if !ok {
+			continue
+		}
+
+		for _, key := range storage.updatedKeys() {
+			if err := c.backend.SetStorage(address, key, storage.slots[key].value); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.accounts = make(map[account.Address]*cachedAccount)
+	c.storage = make(map[account.Address]*cachedStorage)
+	c.journal = nil
+	return nil
+}
+
+// journalAccount records how to restore the cached account, which is replaced
+// rather than modified by the changes
+func (c *Cache) journalAccount(address account.Address) {
+	prev, ok := c.accounts[address]
+	c.journal = append(c.journal, func() {
+		if ok {
+			c.accounts[address] = prev
+		} else {
+			delete(c.accounts, address)
+		}
+	})
+}
+
+// journalStorage records how to restore the cached storage of the account
+func (c *Cache) journalStorage(address account.Address) {
+	prev, ok := c.storage[address]
+	c.journal = append(c.journal, func() {
+		if ok {
+			c.storage[address] = prev
+		} else {
+			delete(c.storage, address)
+		}
+	})
+}
+
+func (c *Cache) accountStorage(address account.Address) *cachedStorage {
+	storage, ok := c.storage[address]
+	if !ok {
+		storage = &cachedStorage{slots: make(map[binary.Word256]*cachedSlot)}
+		c.storage[address] = storage
+	}
+	return storage
+}
+
+// addresses returns the sorted addresses of the cached accounts and storage
+func (c *Cache) addresses() []account.Address {
+	addresses := make([]account.Address, 0, len(c.accounts)+len(c.storage))
+	for address := range c.accounts {
+		addresses = append(addresses, address)
+	}
+	for address := range c.storage {
+		if _, ok := c.accounts[address]; !ok {
+			addresses = append(addresses, address)
+		}
+	}
+
+	sort.Slice(addresses, func(i, j int) bool {
+		return bytes.Compare(addresses[i].Bytes(), addresses[j].Bytes()) < 0
+	})
+	return addresses
+}
+
+// updatedKeys returns the sorted keys of the slots to write back
+func (s *cachedStorage) updatedKeys() []binary.Word256 {
+	var keys []binary.Word256
+	for key, slot := range s.slots {
+		if slot.updated {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].Bytes(), keys[j].Bytes()) < 0
+	})
+	return keys
+}
@@ -0,0 +1,412 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/binary"
+	"github.com/hyperledger/fabric-chaincode-evm/statemanager"
+	"github.com/hyperledger/fabric-chaincode-evm/statemanager/statemanagerfakes"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache", func() {
+	var (
+		cache       *statemanager.Cache
+		fakeBackend *statemanagerfakes.FakeStateWriter
+
+		addr1, addr2 account.Address
+		key1, key2   binary.Word256
+	)
+
+	BeforeEach(func() {
+		fakeBackend = &statemanagerfakes.FakeStateWriter{}
+		cache = statemanager.NewCache(fakeBackend)
+
+		addr1 = account.Address{0x01}
+		addr2 = account.Address{0x02}
+		key1 = binary.LeftPadWord256([]byte{0x01})
+		key2 = binary.LeftPadWord256([]byte{0x02})
+	})
+
+	Context("GetAccount", func() {
+		It("reads each account from the backend once", func() {
+			acc := account.ConcreteAccount{Address: addr1, Sequence: 1}
+			fakeBackend.GetAccountReturns(acc.Account(), nil)
+
+			for i := 0; i < 2; i++ {
+				cached, err := cache.GetAccount(addr1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cached.Sequence()).To(Equal(uint64(1)))
+			}
+			Expect(fakeBackend.GetAccountCallCount()).To(Equal(1))
+		})
+
+		It("remembers accounts that do not exist", func() {
+			for i := 0; i < 2; i++ {
+				acc, err := cache.GetAccount(addr1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(acc).To(BeNil())
+			}
+			Expect(fakeBackend.GetAccountCallCount()).To(Equal(1))
+		})
+
+		It("returns the errors of the backend", func() {
+			fakeBackend.GetAccountReturns(nil, errors.New("boom!"))
+
+			_, err := cache.GetAccount(addr1)
+			Expect(err).To(MatchError("boom!"))
+		})
+
+		It("returns the updated account without writing it", func() {
+			acc := account.ConcreteAccount{Address: addr1, Sequence: 2}
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+
+			cached, err := cache.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached.Sequence()).To(Equal(uint64(2)))
+
+			Expect(fakeBackend.GetAccountCallCount()).To(Equal(0))
+			Expect(fakeBackend.UpdateAccountCallCount()).To(Equal(0))
+		})
+
+		It("returns nil for removed accounts", func() {
+			acc := account.ConcreteAccount{Address: addr1}
+			fakeBackend.GetAccountReturns(acc.Account(), nil)
+
+			Expect(cache.RemoveAccount(addr1)).To(Succeed())
+
+			cached, err := cache.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached).To(BeNil())
+			Expect(fakeBackend.GetAccountCallCount()).To(Equal(0))
+			Expect(fakeBackend.RemoveAccountCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("GetStorage", func() {
+		It("reads each slot from the backend once", func() {
+			fakeBackend.GetStorageReturns(binary.LeftPadWord256([]byte{0x11}), nil)
+
+			for i := 0; i < 2; i++ {
+				Expect(cache.GetStorage(addr1, key1)).To(Equal(binary.LeftPadWord256([]byte{0x11})))
+			}
+			Expect(fakeBackend.GetStorageCallCount()).To(Equal(1))
+		})
+
+		It("returns the value set without writing it", func() {
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x22}))).To(Succeed())
+
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(binary.LeftPadWord256([]byte{0x22})))
+			Expect(fakeBackend.GetStorageCallCount()).To(Equal(0))
+			Expect(fakeBackend.SetStorageCallCount()).To(Equal(0))
+		})
+
+		It("returns the zero word for the slots of removed accounts", func() {
+			fakeBackend.GetStorageReturns(binary.LeftPadWord256([]byte{0x11}), nil)
+			Expect(cache.RemoveAccount(addr1)).To(Succeed())
+
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(binary.Word256{}))
+			Expect(fakeBackend.GetStorageCallCount()).To(Equal(0))
+		})
+
+		It("returns the errors of the backend", func() {
+			fakeBackend.GetStorageReturns(binary.Word256{}, errors.New("boom!"))
+
+			_, err := cache.GetStorage(addr1, key1)
+			Expect(err).To(MatchError("boom!"))
+		})
+	})
+
+	Context("UpdateAccount", func() {
+		It("is not affected by later changes to the account", func() {
+			acc := account.ConcreteAccount{Address: addr1, Code: []byte{0x60}}
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			acc.Code[0] = 0x00
+
+			cached, err := cache.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached.Code()).To(Equal(account.Bytecode{0x60}))
+		})
+	})
+
+	Context("Flush", func() {
+		var writes []string
+
+		BeforeEach(func() {
+			writes = nil
+			fakeBackend.RemoveAccountStub = func(address account.Address) error {
+				writes = append(writes, fmt.Sprintf("remove %x", address.Bytes()[0]))
+				return nil
+			}
+			fakeBackend.UpdateAccountStub = func(acc account.Account) error {
+				writes = append(writes, fmt.Sprintf("update %x", acc.Address().Bytes()[0]))
+				return nil
+			}
+			fakeBackend.SetStorageStub = func(address account.Address, key, value binary.Word256) error {
+				writes = append(writes, fmt.Sprintf("set %x %x=%x", address.Bytes()[0], key.Bytes()[31], value.Bytes()[31]))
+				return nil
+			}
+		})
+
+		It("writes the changes in the order of addresses and keys", func() {
+			acc1 := account.ConcreteAccount{Address: addr1}
+			acc2 := account.ConcreteAccount{Address: addr2}
+
+			Expect(cache.SetStorage(addr2, key2, binary.LeftPadWord256([]byte{0x22}))).To(Succeed())
+			Expect(cache.SetStorage(addr2, key1, binary.LeftPadWord256([]byte{0x21}))).To(Succeed())
+			Expect(cache.UpdateAccount(acc2.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+			Expect(cache.UpdateAccount(acc1.Account())).To(Succeed())
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(writes).To(Equal([]string{
+				"update 1",
+				"set 1 1=11",
+				"update 2",
+				"set 2 1=21",
+				"set 2 2=22",
+			}))
+		})
+
+		It("only writes the last value of a slot", func() {
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x12}))).To(Succeed())
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(writes).To(Equal([]string{"set 1 1=12"}))
+		})
+
+		It("does not write what was only read", func() {
+			_, err := cache.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = cache.GetStorage(addr1, key1)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(writes).To(BeEmpty())
+		})
+
+		It("removes accounts before writing them back", func() {
+			acc := account.ConcreteAccount{Address: addr1}
+			Expect(cache.SetStorage(addr1, key2, binary.LeftPadWord256([]byte{0x12}))).To(Succeed())
+			Expect(cache.RemoveAccount(addr1)).To(Succeed())
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(writes).To(Equal([]string{
+				"remove 1",
+				"update 1",
+				"set 1 1=11",
+			}))
+		})
+
+		It("empties the cache", func() {
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+			Expect(cache.Flush()).To(Succeed())
+			Expect(cache.Flush()).To(Succeed())
+			Expect(writes).To(HaveLen(1))
+
+			_, err := cache.GetStorage(addr1, key1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fakeBackend.GetStorageCallCount()).To(Equal(1))
+		})
+
+		It("returns the errors of the backend", func() {
+			fakeBackend.SetStorageReturns(errors.New("boom!"))
+			fakeBackend.SetStorageStub = nil
+
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+			Expect(cache.Flush()).To(MatchError("boom!"))
+		})
+	})
+
+	Context("snapshots", func() {
+		value := func(b byte) binary.Word256 {
+			return binary.LeftPadWord256([]byte{b})
+		}
+
+		It("reverts the changes made since the snapshot", func() {
+			acc := account.ConcreteAccount{Address: addr1, Sequence: 1}
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, value(0x11))).To(Succeed())
+
+			snapshot := cache.Snapshot()
+			acc.Sequence = 2
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, value(0x12))).To(Succeed())
+			Expect(cache.SetStorage(addr1, key2, value(0x22))).To(Succeed())
+
+			cache.RevertToSnapshot(snapshot)
+
+			cached, err := cache.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached.Sequence()).To(Equal(uint64(1)))
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(value(0x11)))
+			Expect(cache.GetStorage(addr1, key2)).To(Equal(binary.Word256{}))
+		})
+
+		It("reads reverted values from the backend again", func() {
+			fakeBackend.GetStorageReturns(value(0x01), nil)
+
+			snapshot := cache.Snapshot()
+			Expect(cache.SetStorage(addr1, key1, value(0x11))).To(Succeed())
+			cache.RevertToSnapshot(snapshot)
+
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(value(0x01)))
+			Expect(fakeBackend.GetStorageCallCount()).To(Equal(1))
+		})
+
+		It("reverts nested calls independently", func() {
+			// The outer call sets a slot, the first inner call succeeds and
+			// makes a call that fails, and the second inner call fails
+			Expect(cache.SetStorage(addr1, key1, value(0x01))).To(Succeed())
+
+			cache.Snapshot()
+			Expect(cache.SetStorage(addr2, key1, value(0x02))).To(Succeed())
+
+			innermost := cache.Snapshot()
+			Expect(cache.SetStorage(addr2, key1, value(0x03))).To(Succeed())
+			Expect(cache.SetStorage(addr1, key2, value(0x03))).To(Succeed())
+			cache.RevertToSnapshot(innermost)
+
+			Expect(cache.GetStorage(addr2, key1)).To(Equal(value(0x02)))
+			Expect(cache.GetStorage(addr1, key2)).To(Equal(binary.Word256{}))
+
+			inner2 := cache.Snapshot()
+			Expect(cache.SetStorage(addr1, key1, value(0x04))).To(Succeed())
+			cache.RevertToSnapshot(inner2)
+
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(value(0x01)))
+			Expect(cache.GetStorage(addr2, key1)).To(Equal(value(0x02)))
+		})
+
+		It("reverts several call depths at once", func() {
+			Expect(cache.SetStorage(addr1, key1, value(0x01))).To(Succeed())
+
+			outer := cache.Snapshot()
+			for depth := byte(2); depth < 6; depth++ {
+				cache.Snapshot()
+				Expect(cache.SetStorage(addr1, key1, value(depth))).To(Succeed())
+			}
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(value(0x05)))
+
+			cache.RevertToSnapshot(outer)
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(value(0x01)))
+		})
+
+		It("reverts the removal of accounts", func() {
+			acc := account.ConcreteAccount{Address: addr1, Sequence: 1}
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, value(0x11))).To(Succeed())
+
+			snapshot := cache.Snapshot()
+			Expect(cache.RemoveAccount(addr1)).To(Succeed())
+			Expect(cache.GetAccount(addr1)).To(BeNil())
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(binary.Word256{}))
+
+			cache.RevertToSnapshot(snapshot)
+
+			cached, err := cache.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cached.Sequence()).To(Equal(uint64(1)))
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(value(0x11)))
+		})
+
+		It("reverts the changes made after a removal", func() {
+			Expect(cache.RemoveAccount(addr1)).To(Succeed())
+
+			snapshot := cache.Snapshot()
+			acc := account.ConcreteAccount{Address: addr1}
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, value(0x11))).To(Succeed())
+			cache.RevertToSnapshot(snapshot)
+
+			Expect(cache.GetAccount(addr1)).To(BeNil())
+			Expect(cache.GetStorage(addr1, key1)).To(Equal(binary.Word256{}))
+			Expect(fakeBackend.GetStorageCallCount()).To(Equal(0))
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(fakeBackend.RemoveAccountCallCount()).To(Equal(1))
+			Expect(fakeBackend.UpdateAccountCallCount()).To(Equal(0))
+			Expect(fakeBackend.SetStorageCallCount()).To(Equal(0))
+		})
+
+		It("does not write reverted changes", func() {
+			Expect(cache.SetStorage(addr1, key1, value(0x11))).To(Succeed())
+
+			snapshot := cache.Snapshot()
+			Expect(cache.RemoveAccount(addr2)).To(Succeed())
+			Expect(cache.SetStorage(addr1, key2, value(0x12))).To(Succeed())
+			cache.RevertToSnapshot(snapshot)
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(fakeBackend.RemoveAccountCallCount()).To(Equal(0))
+			Expect(fakeBackend.SetStorageCallCount()).To(Equal(1))
+			address, key, val := fakeBackend.SetStorageArgsForCall(0)
+			Expect(address).To(Equal(addr1))
+			Expect(key).To(Equal(key1))
+			Expect(val).To(Equal(value(0x11)))
+		})
+
+		It("panics on snapshots that cannot be reverted to", func() {
+			Expect(cache.SetStorage(addr1, key1, value(0x11))).To(Succeed())
+			snapshot := cache.Snapshot()
+			Expect(cache.Flush()).To(Succeed())
+
+			Expect(func() { cache.RevertToSnapshot(snapshot) }).To(Panic())
+			Expect(func() { cache.RevertToSnapshot(-1) }).To(Panic())
+		})
+	})
+
+	Context("in front of the world state", func() {
+		var stub *shim.MockStub
+
+		BeforeEach(func() {
+			stub = shim.NewMockStub("evmscc", nil)
+			stub.MockTransactionStart("tx1")
+			cache = statemanager.NewCache(statemanager.NewStateManager(stub))
+		})
+
+		AfterEach(func() {
+			stub.MockTransactionEnd("tx1")
+		})
+
+		It("only writes to the world state when flushed", func() {
+			acc := account.ConcreteAccount{Address: addr1, Code: []byte{0x60, 0x80}}
+			Expect(cache.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(cache.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+			Expect(stub.State).To(BeEmpty())
+
+			Expect(cache.Flush()).To(Succeed())
+			Expect(stub.State).To(HaveLen(2))
+
+			sm := statemanager.NewStateManager(stub)
+			persisted, err := sm.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted.Code()).To(Equal(account.Bytecode{0x60, 0x80}))
+			Expect(sm.GetStorage(addr1, key1)).To(Equal(binary.LeftPadWord256([]byte{0x11})))
+		})
+
+		It("removes the storage of removed accounts", func() {
+			sm := statemanager.NewStateManager(stub)
+			acc := account.ConcreteAccount{Address: addr1}
+			Expect(sm.UpdateAccount(acc.Account())).To(Succeed())
+			Expect(sm.SetStorage(addr1, key1, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+
+			Expect(cache.RemoveAccount(addr1)).To(Succeed())
+			Expect(cache.Flush()).To(Succeed())
+			Expect(stub.State).To(BeEmpty())
+		})
+	})
+})
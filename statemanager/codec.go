@@ -0,0 +1,33 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/binary"
+)
+
+// convertToWord256 decodes a storage value. Absent values are the zero word
+// and values shorter than a word are left padded with zeros, the way the EVM
+// reads them.
+func convertToWord256(value []byte) (binary.Word256, error) {
+	if len(value) > binary.Word256Length {
+		return binary.Word256{}, fmt.Errorf("storage value of %d bytes exceeds 256 bits", len(value))
+	}
+
+	return binary.LeftPadWord256(value), nil
+}
+
+// convertToBytecode copies the code so that the account does not share its
+// code with the caller's buffer
+func convertToBytecode(value []byte) account.Bytecode {
+	convertedVal := make(account.Bytecode, len(value))
+	copy(convertedVal, value)
+	return convertedVal
+}
@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager
+
+import (
+	"bytes"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/binary"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("codec", func() {
+	Context("convertToWord256", func() {
+		It("returns the zero word for absent values", func() {
+			Expect(convertToWord256(nil)).To(Equal(binary.Word256{}))
+			Expect(convertToWord256([]byte{})).To(Equal(binary.Word256{}))
+		})
+
+		It("decodes full words", func() {
+			value := bytes.Repeat([]byte{0xab}, binary.Word256Length)
+			word, err := convertToWord256(value)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(word.Bytes()).To(Equal(value))
+		})
+
+		It("left pads short values", func() {
+			word, err := convertToWord256([]byte{0x12, 0x34})
+			Expect(err).ToNot(HaveOccurred())
+
+			expected := binary.Word256{}
+			expected[30], expected[31] = 0x12, 0x34
+			Expect(word).To(Equal(expected))
+		})
+
+		It("rejects values larger than a word", func() {
+			_, err := convertToWord256(make([]byte, binary.Word256Length+1))
+			Expect(err).To(MatchError("storage value of 33 bytes exceeds 256 bits"))
+		})
+
+		It("does not alias the value", func() {
+			value := []byte{0x01}
+			word, err := convertToWord256(value)
+			Expect(err).ToNot(HaveOccurred())
+
+			value[0] = 0x02
+			Expect(word[31]).To(Equal(byte(0x01)))
+		})
+	})
+
+	Context("convertToBytecode", func() {
+		It("round trips code exactly", func() {
+			for _, code := range [][]byte{
+				{},
+				{0x00},
+				{0x60, 0x80, 0x60, 0x40, 0x52, 0x00},
+				bytes.Repeat([]byte{0xfe}, 24576),
+			} {
+				Expect(convertToBytecode(code).Bytes()).To(Equal(code))
+			}
+		})
+
+		It("copies the code", func() {
+			code := []byte{0x60, 0x80}
+			converted := convertToBytecode(code)
+
+			code[0] = 0x00
+			Expect(converted).To(Equal(account.Bytecode{0x60, 0x80}))
+		})
+	})
+})
@@ -7,13 +7,23 @@ SPDX-License-Identifier: Apache-2.0
 package statemanager
 
 import (
-	"errors"
+	"encoding/hex"
 
 	"github.com/hyperledger/burrow/account"
 	"github.com/hyperledger/burrow/binary"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 )
 
+// The namespaces of the world state keys. The record of an account, which
+// holds the code of a contract, is stored under the composite key of the
+// account namespace and its address, and each storage slot under the
+// composite key of the storage namespace, the address of the contract and the
+// slot, so contracts never share keys.
+const (
+	AccountNamespace = "account"
+	StorageNamespace = "storage"
+)
+
 type StateWriter interface {
 	GetAccount(address account.Address) (account.Account, error)
 	GetStorage(address account.Address, key binary.Word256) (binary.Word256, error)
@@ -26,54 +36,123 @@ type stateWriter struct {
 	stub shim.ChaincodeStubInterface
 }
 
+// NewStateManager returns a StateWriter keeping the EVM state in the world
+// state of the chaincode
+func NewStateManager(stub shim.ChaincodeStubInterface) StateWriter {
+	return &stateWriter{stub: stub}
+}
+
+// GetAccount returns nil when the account does not exist
 func (s *stateWriter) GetAccount(address account.Address) (account.Account, error) {
-	code, err := s.stub.GetState(address.String())
+	key, err := s.accountKey(address)
 	if err != nil {
 		return nil, err
 	}
 
-	acc := account.ConcreteAccount{
-		Address: address,
-		Balance: 0,
-		Code:    convertToBytecode(code),
+	data, err := s.stub.GetState(key)
+	if err != nil {
+		return nil, err
 	}
 
-	return acc.Account(), nil
+	if data == nil {
+		return nil, nil
+	}
 
+	return decodeAccount(address, data)
 }
 
+// GetStorage returns the zero word for slots that were never set
 func (s *stateWriter) GetStorage(address account.Address, key binary.Word256) (binary.Word256, error) {
-	val, err := s.stub.GetState(key.String())
+	compositeKey, err := s.storageKey(address, key)
 	if err != nil {
 		return binary.Word256{}, err
 	}
 
-	if len(val) != binary.Word256Length {
-		return binary.Word256{}, errors.New("Value is greater than 256 bits")
+	val, err := s.stub.GetState(compositeKey)
+	if err != nil {
+		return binary.Word256{}, err
 	}
-	return convertToWord256(val), nil
+
+	return convertToWord256(val)
 }
 
 func (s *stateWriter) UpdateAccount(updatedAccount account.Account) error {
-	return s.stub.PutState(updatedAccount.Address().String(), updatedAccount.Code().Bytes())
+	key, err := s.accountKey(updatedAccount.Address())
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeAccount(updatedAccount)
+	if err != nil {
+		return err
+	}
+
+	return s.stub.PutState(key, data)
 }
 
+// RemoveAccount deletes the record of the account along with all of its
+// storage
 func (s *stateWriter) RemoveAccount(address account.Address) error {
-	return s.stub.DelState(address.String())
+	key, err := s.accountKey(address)
+	if err != nil {
+		return err
+	}
+
+	storageKeys, err := s.storageKeys(address)
+	if err != nil {
+		return err
+	}
+
+	for _, storageKey := range storageKeys {
+		if err := s.stub.DelState(storageKey); err != nil {
+			return err
+		}
+	}
+
+	return s.stub.DelState(key)
 }
 
+// SetStorage deletes the slot when the value is the zero word, as SSTORE of 0
+// does in the EVM, since GetStorage returns the zero word for missing slots
 func (s *stateWriter) SetStorage(address account.Address, key, value binary.Word256) error {
-	return s.stub.PutState(address.String(), value.Bytes())
+	compositeKey, err := s.storageKey(address, key)
+	if err != nil {
+		return err
+	}
+
+	if value.IsZero() {
+		return s.stub.DelState(compositeKey)
+	}
+
+	return s.stub.PutState(compositeKey, value.Bytes())
 }
 
-func convertToWord256(value []byte) binary.Word256 {
-	convertedVal := binary.Word256{}
-	copy(value[:], convertedVal[:])
-	return convertedVal
+func (s *stateWriter) accountKey(address account.Address) (string, error) {
+	return s.stub.CreateCompositeKey(AccountNamespace, []string{hex.EncodeToString(address.Bytes())})
 }
 
-func convertToBytecode(value []byte) account.Bytecode {
-	convertedVal := account.Bytecode{}
-	copy(value[:], convertedVal[:])
-	return convertedVal
+func (s *stateWriter) storageKey(address account.Address, key binary.Word256) (string, error) {
+	return s.stub.CreateCompositeKey(StorageNamespace, []string{hex.EncodeToString(address.Bytes()), hex.EncodeToString(key.Bytes())})
+}
+
+// storageKeys lists the keys of the storage slots of the account. The keys
+// are collected before any of them is deleted so the iterator is never
+// invalidated.
+func (s *stateWriter) storageKeys(address account.Address) ([]string, error) {
+	iter, err := s.stub.GetStateByPartialCompositeKey(StorageNamespace, []string{hex.EncodeToString(address.Bytes())})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, kv.GetKey())
+	}
+
+	return keys, nil
 }
@@ -0,0 +1,214 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package statemanager_test
+
+import (
+	"encoding/hex"
+
+	"github.com/hyperledger/burrow/account"
+	"github.com/hyperledger/burrow/binary"
+	ptypes "github.com/hyperledger/burrow/permission/types"
+	"github.com/hyperledger/fabric-chaincode-evm/statemanager"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Statemanager", func() {
+	var (
+		sm   statemanager.StateWriter
+		stub *shim.MockStub
+
+		addr1, addr2 account.Address
+		slot         binary.Word256
+	)
+
+	accountKey := func(address account.Address) string {
+		key, err := stub.CreateCompositeKey(statemanager.AccountNamespace, []string{hex.EncodeToString(address.Bytes())})
+		Expect(err).ToNot(HaveOccurred())
+		return key
+	}
+
+	storageKey := func(address account.Address, slot binary.Word256) string {
+		key, err := stub.CreateCompositeKey(statemanager.StorageNamespace, []string{hex.EncodeToString(address.Bytes()), hex.EncodeToString(slot.Bytes())})
+		Expect(err).ToNot(HaveOccurred())
+		return key
+	}
+
+	BeforeEach(func() {
+		stub = shim.NewMockStub("evmscc", nil)
+		stub.MockTransactionStart("tx1")
+		sm = statemanager.NewStateManager(stub)
+
+		addr1 = account.Address{0x01}
+		addr2 = account.Address{0x02}
+		slot = binary.LeftPadWord256([]byte{0x07})
+	})
+
+	AfterEach(func() {
+		stub.MockTransactionEnd("tx1")
+	})
+
+	Context("SetStorage", func() {
+		It("keeps the storage of contracts apart", func() {
+			value1 := binary.LeftPadWord256([]byte{0x11})
+			value2 := binary.LeftPadWord256([]byte{0x22})
+
+			Expect(sm.SetStorage(addr1, slot, value1)).To(Succeed())
+			Expect(sm.SetStorage(addr2, slot, value2)).To(Succeed())
+
+			Expect(stub.State).To(HaveLen(2))
+			Expect(stub.State[storageKey(addr1, slot)]).To(Equal(value1.Bytes()))
+			Expect(stub.State[storageKey(addr2, slot)]).To(Equal(value2.Bytes()))
+		})
+
+		It("does not overwrite the account of the contract", func() {
+			acc := account.ConcreteAccount{Address: addr1, Code: []byte{0x60, 0x60, 0x60, 0x40}}
+			Expect(sm.UpdateAccount(acc.Account())).To(Succeed())
+			record := stub.State[accountKey(addr1)]
+
+			Expect(sm.SetStorage(addr1, binary.Word256{}, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+
+			Expect(stub.State).To(HaveLen(2))
+			Expect(stub.State[accountKey(addr1)]).To(Equal(record))
+		})
+
+		It("deletes the slot when it is set to the zero word", func() {
+			Expect(sm.SetStorage(addr1, slot, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+			Expect(sm.SetStorage(addr2, slot, binary.LeftPadWord256([]byte{0x22}))).To(Succeed())
+
+			Expect(sm.SetStorage(addr1, slot, binary.Word256{})).To(Succeed())
+
+			Expect(stub.State).ToNot(HaveKey(storageKey(addr1, slot)))
+			Expect(stub.State).To(HaveKey(storageKey(addr2, slot)))
+			Expect(sm.GetStorage(addr1, slot)).To(Equal(binary.Word256{}))
+		})
+
+		It("does not store slots that were never set when they are set to the zero word", func() {
+			Expect(sm.SetStorage(addr1, slot, binary.Word256{})).To(Succeed())
+			Expect(stub.State).To(BeEmpty())
+		})
+
+		It("stores each slot under a composite key of the address and slot", func() {
+			Expect(sm.SetStorage(addr1, slot, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+
+			for key := range stub.State {
+				namespace, attributes, err := stub.SplitCompositeKey(key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(namespace).To(Equal(statemanager.StorageNamespace))
+				Expect(attributes).To(Equal([]string{hex.EncodeToString(addr1.Bytes()), hex.EncodeToString(slot.Bytes())}))
+			}
+		})
+	})
+
+	Context("GetStorage", func() {
+		It("returns the zero word for slots that were never set", func() {
+			value, err := sm.GetStorage(addr1, slot)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(binary.Word256{}))
+		})
+
+		It("returns the value set in the slot of the contract", func() {
+			value1 := binary.LeftPadWord256([]byte{0x11})
+			value2 := binary.LeftPadWord256([]byte{0x22})
+			Expect(sm.SetStorage(addr1, slot, value1)).To(Succeed())
+			Expect(sm.SetStorage(addr2, slot, value2)).To(Succeed())
+
+			Expect(sm.GetStorage(addr1, slot)).To(Equal(value1))
+			Expect(sm.GetStorage(addr2, slot)).To(Equal(value2))
+		})
+
+		It("returns an error for values larger than a word", func() {
+			Expect(stub.PutState(storageKey(addr1, slot), make([]byte, 33))).To(Succeed())
+
+			_, err := sm.GetStorage(addr1, slot)
+			Expect(err).To(MatchError("storage value of 33 bytes exceeds 256 bits"))
+		})
+	})
+
+	Context("GetAccount", func() {
+		It("returns nil for accounts that do not exist", func() {
+			acc, err := sm.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(acc).To(BeNil())
+		})
+
+		It("returns an error for corrupted records", func() {
+			Expect(stub.PutState(accountKey(addr1), []byte{0xff})).To(Succeed())
+
+			_, err := sm.GetAccount(addr1)
+			Expect(err).To(MatchError("unsupported account record version 255"))
+		})
+	})
+
+	Context("UpdateAccount", func() {
+		It("persists the nonce, balance and permissions of the account", func() {
+			permissions := ptypes.AccountPermissions{
+				Base:  ptypes.BasePermissions{Perms: ptypes.Call | ptypes.CreateContract, SetBit: ptypes.Call | ptypes.CreateContract},
+				Roles: []string{"deployer"},
+			}
+			acc := account.ConcreteAccount{Address: addr1, Sequence: 3, Balance: 100, Permissions: permissions}
+			Expect(sm.UpdateAccount(acc.Account())).To(Succeed())
+
+			persisted, err := sm.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted.Address()).To(Equal(addr1))
+			Expect(persisted.Sequence()).To(Equal(uint64(3)))
+			Expect(persisted.Balance()).To(Equal(uint64(100)))
+			Expect(persisted.Permissions()).To(Equal(permissions))
+		})
+
+		It("round trips the code of the account", func() {
+			code := []byte{0x60, 0x80, 0x60, 0x40, 0x52, 0x00}
+			acc := account.ConcreteAccount{Address: addr1, Code: code}
+			Expect(sm.UpdateAccount(acc.Account())).To(Succeed())
+
+			persisted, err := sm.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted.Code().Bytes()).To(Equal(code))
+		})
+
+		It("keeps the accounts of contracts apart", func() {
+			acc1 := account.ConcreteAccount{Address: addr1, Sequence: 1, Code: []byte{0x01}}
+			acc2 := account.ConcreteAccount{Address: addr2, Sequence: 2, Code: []byte{0x02}}
+			Expect(sm.UpdateAccount(acc1.Account())).To(Succeed())
+			Expect(sm.UpdateAccount(acc2.Account())).To(Succeed())
+
+			persisted1, err := sm.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted1.Sequence()).To(Equal(uint64(1)))
+			Expect(persisted1.Code()).To(Equal(account.Bytecode{0x01}))
+
+			persisted2, err := sm.GetAccount(addr2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(persisted2.Sequence()).To(Equal(uint64(2)))
+			Expect(persisted2.Code()).To(Equal(account.Bytecode{0x02}))
+		})
+	})
+
+	Context("RemoveAccount", func() {
+		It("removes the record and storage of the account only", func() {
+			for _, address := range []account.Address{addr1, addr2} {
+				acc := account.ConcreteAccount{Address: address, Code: []byte{0x60}}
+				Expect(sm.UpdateAccount(acc.Account())).To(Succeed())
+				Expect(sm.SetStorage(address, slot, binary.LeftPadWord256([]byte{0x11}))).To(Succeed())
+				Expect(sm.SetStorage(address, binary.Word256{}, binary.LeftPadWord256([]byte{0x22}))).To(Succeed())
+			}
+
+			Expect(sm.RemoveAccount(addr1)).To(Succeed())
+
+			acc, err := sm.GetAccount(addr1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(acc).To(BeNil())
+
+			Expect(stub.State).To(HaveLen(3))
+			Expect(stub.State).To(HaveKey(accountKey(addr2)))
+			Expect(stub.State).To(HaveKey(storageKey(addr2, slot)))
+			Expect(stub.State).To(HaveKey(storageKey(addr2, binary.Word256{})))
+		})
+	})
+})
@@ -4,20 +4,61 @@ Copyright IBM Corp. All Rights Reserved.
 SPDX-License-Identifier: Apache-2.0
 */
 var Web3 = require('web3');
-var user = process.env.ETHSERVER_USER
-var web3 = new Web3(new Web3.providers.HttpProvider('http://localhost:5000'));
-if (user == "User2"){
-  var web3 = new Web3(new Web3.providers.HttpProvider('http://localhost:5001'));
-}
+var user = process.env.ETHSERVER_USER || "User1"
+// The proxy makes the requests with the identity named in the X-Fabric-User header
+var headers = [{name: "X-Fabric-User", value: user}];
+var web3 = new Web3(new Web3.providers.HttpProvider('http://localhost:5000', 0, undefined, undefined, headers));
 web3.eth.defaultAccount = web3.eth.accounts[0];
 console.log("Account: " + web3.eth.defaultAccount);
 
-function deploy(v) {
+// The receipt is null until the transaction is committed. It is polled for
+// with a delay doubling from a quarter of a second up to a few seconds, until
+// the receipt timeout.
+var receiptTimeout = 5 * 60 * 1000;
+
+function waitForReceipt(txHash, callback) {
+  var delay = 250;
+  var deadline = Date.now() + receiptTimeout;
+
+  function poll() {
+    web3.eth.getTransactionReceipt(txHash, function(err, receipt) {
+      if (err) {
+        return callback(err);
+      }
+      if (receipt != null) {
+        if (receipt.status == "0x0") {
+          return callback(new Error("transaction " + txHash + " failed: " + receipt.validationCode));
+        }
+        return callback(null, receipt);
+      }
+      if (Date.now() > deadline) {
+        return callback(new Error("timed out waiting for the receipt of transaction " + txHash));
+      }
+      setTimeout(poll, delay);
+      delay = Math.min(delay * 2, 4000);
+    });
+  }
+
+  poll();
+}
+
+function deploy(v, callback) {
   console.log("deploying contract...");
   var deployedContract = v.new(['a','b'], {data: votingBytecode});
-  var address = web3.eth.getTransactionReceipt(deployedContract.transactionHash).ContractAddress;
-  console.log("contract: " + address);
-  return v.at(address);
+  waitForReceipt(deployedContract.transactionHash, function(err, receipt) {
+    if (err) {
+      return callback(err);
+    }
+    console.log("contract: " + receipt.contractAddress);
+    callback(null, v.at(receipt.contractAddress));
+  });
+}
+
+function exitOnError(err) {
+  if (err) {
+    console.log(err.message);
+    process.exit(1);
+  }
 }
 
 var votingABI = [
@@ -179,17 +220,23 @@ var myContract = null;
 
 switch(process.argv[2]){
   case "deploy":
-    myContract = deploy(VotingContract);
-    console.log("Proposals")
-    console.log("proposal[0]: " + myContract.proposals('0').toString());
-    console.log("proposal[1]: " + myContract.proposals('1').toString());
-    process.exit()
+    deploy(VotingContract, function(err, myContract) {
+      exitOnError(err);
+      console.log("Proposals")
+      console.log("proposal[0]: " + myContract.proposals('0').toString());
+      console.log("proposal[1]: " + myContract.proposals('1').toString());
+      process.exit()
+    });
+    break;
   case "giveRightToVote":
     var contractAddr = process.argv[3];
     var userAddr = process.argv[4] 
     myContract = VotingContract.at(contractAddr);
-    myContract.giveRightToVote(userAddr)
-    process.exit()
+    waitForReceipt(myContract.giveRightToVote(userAddr), function(err) {
+      exitOnError(err);
+      process.exit()
+    });
+    break;
   case "vote":
     var address = process.argv[3];
     myContract = VotingContract.at(address);
@@ -198,11 +245,14 @@ switch(process.argv[2]){
     console.log("proposal[0]: " + myContract.proposals('0').toString());
     console.log("proposal[1]: " + myContract.proposals('1').toString());
     console.log("Voting for proposal: " + proposal)
-    myContract.vote(proposal)
-    console.log("After Voting: ")
-    console.log("proposal[0]: " + myContract.proposals('0').toString());
-    console.log("proposal[1]: " + myContract.proposals('1').toString());
-    process.exit()
+    waitForReceipt(myContract.vote(proposal), function(err) {
+      exitOnError(err);
+      console.log("After Voting: ")
+      console.log("proposal[0]: " + myContract.proposals('0').toString());
+      console.log("proposal[1]: " + myContract.proposals('1').toString());
+      process.exit()
+    });
+    break;
   default:
     console.log("Please specify an action: deploy, giveRightToVote, vote")
     process.exit()